@@ -0,0 +1,55 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"code_for_article/ruleengine/agenda"
+	"code_for_article/ruleengine/model"
+)
+
+func TestFieldGetterPrefersGeneratedAccessor(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	tx := model.Transaction{ID: 1, Amount: 2500}
+
+	generated, ok := model.LookupAccessor(reflect.TypeOf(tx), "Amount")
+	if !ok {
+		t.Fatalf("model.Transaction 应有生成的读取函数，请运行 go generate ./model")
+	}
+	if v, ok := generated(&tx); !ok || v != 2500.0 {
+		t.Fatalf("生成的读取函数应同时支持指针事实，实际 %v, %v", v, ok)
+	}
+
+	// 没有生成读取函数的类型回退到反射
+	get := b.fieldGetter(reflect.TypeOf(scheduled{}), "Amount")
+	if v, ok := get(scheduled{Amount: 3}); !ok || v != 3.0 {
+		t.Fatalf("反射读取期望 3，实际 %v, %v", v, ok)
+	}
+}
+
+func benchmarkConstraint(b *testing.B, factType reflect.Type) {
+	builder := NewBuilder(agenda.New(), nil)
+	test, err := builder.compileConstraint(model.Constraint{Field: "Amount", Operator: ">", Value: 10000}, factType)
+	if err != nil {
+		b.Fatalf("编译约束失败: %v", err)
+	}
+	var fact model.Fact = model.Transaction{ID: 1, Amount: 25000}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !test(fact) {
+			b.Fatal("约束应满足")
+		}
+	}
+}
+
+// BenchmarkConstraintGenerated 使用 factgen 生成的读取函数。
+func BenchmarkConstraintGenerated(b *testing.B) {
+	benchmarkConstraint(b, reflect.TypeOf(model.Transaction{}))
+}
+
+// BenchmarkConstraintReflection 事实类型未知，回退到反射。
+func BenchmarkConstraintReflection(b *testing.B) {
+	benchmarkConstraint(b, nil)
+}
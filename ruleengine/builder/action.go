@@ -0,0 +1,303 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+
+	"code_for_article/ruleengine/model"
+	"code_for_article/ruleengine/rete"
+)
+
+// WorkingMemory 是规则动作修改工作内存所需的接口，由 Engine 实现。
+// 动作通过它插入、撤回、修改事实，新的匹配结果会再次进入 Agenda，形成前向链式推理。
+type WorkingMemory interface {
+	AddFact(f model.Fact)
+	RetractFact(f model.Fact)
+	UpdateFact(f model.Fact)
+
+	// AddLogicalFact 插入由 justification 支撑的逻辑事实，
+	// RemoveJustification 移除依据，失去全部依据的逻辑事实会被自动撤回；
+	// KeepJustification 在 Token 撤回后于同一次传播中重新匹配时取消移除。
+	AddLogicalFact(f model.Fact, justification string)
+	RemoveJustification(justification string)
+	KeepJustification(justification string)
+}
+
+// justificationOf 返回规则激活 Token 作为依据时的标识。
+func justificationOf(ruleName string, tok rete.Token) string {
+	return ruleName + "|" + tok.Hash()
+}
+
+// resolve 在 Token 中解析引用的值。
+func (b *Builder) resolve(ref factRef, token rete.Token) (interface{}, error) {
+	if ref.index >= len(token.Facts) {
+		return nil, fmt.Errorf("事实引用 $%d 超出范围，Token 仅有 %d 个事实", ref.index, len(token.Facts))
+	}
+	fact := token.Facts[ref.index]
+	if ref.field == "" {
+		return fact, nil
+	}
+	val := b.getFieldValue(fact, ref.field)
+	if val == nil {
+		return nil, fmt.Errorf("事实 %s 没有字段 %s", fact.Key(), ref.field)
+	}
+	return val, nil
+}
+
+// compileData 预先解析 Data 中的引用，返回在运行时求值的字段表。
+func compileData(data map[string]interface{}, sc *scope) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(data))
+	for field, v := range data {
+		if isFactRef(v) {
+			ref, err := sc.parseRef(v.(string))
+			if err != nil {
+				return nil, err
+			}
+			out[field] = ref
+			continue
+		}
+		out[field] = v
+	}
+	return out, nil
+}
+
+// checkActionRefs 检查动作中的事实引用在各 OR 分支中是否指向 Token 中的同一位置。
+// 动作只编译一次，若同一引用在不同分支指向不同事实，运行时将取到错误的事实。
+func checkActionRefs(action model.Action, scopes []*scope) error {
+	var refs []string
+	if action.Target != "" {
+		refs = append(refs, action.Target)
+	}
+	for _, v := range action.Data {
+		if isFactRef(v) {
+			refs = append(refs, v.(string))
+		}
+	}
+
+	for _, r := range refs {
+		want, err := scopes[0].parseRef(r)
+		if err != nil {
+			continue // 由 createAction 报告
+		}
+		for n, sc := range scopes[1:] {
+			got, err := sc.parseRef(r)
+			if err != nil {
+				return fmt.Errorf("动作引用 %s 在第 %d 个 OR 分支中无法解析: %w", r, n+2, err)
+			}
+			if got != want {
+				return fmt.Errorf("动作引用 %s 在各 OR 分支中指向不同的事实", r)
+			}
+		}
+	}
+	return nil
+}
+
+// applyData 将 Data 中的字段写入 target（可寻址的结构体）。
+func (b *Builder) applyData(target reflect.Value, data map[string]interface{}, token rete.Token) error {
+	for field, v := range data {
+		if ref, ok := v.(factRef); ok {
+			val, err := b.resolve(ref, token)
+			if err != nil {
+				return err
+			}
+			v = val
+		}
+		if err := setFieldValue(target, field, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setFieldValue 为结构体字段赋值，数值类型之间会自动转换；DynamicFact 写入属性。
+func setFieldValue(target reflect.Value, name string, value interface{}) error {
+	if d, ok := target.Addr().Interface().(*model.DynamicFact); ok {
+		d.Set(name, value)
+		return nil
+	}
+	field := target.FieldByName(name)
+	if !field.IsValid() || !field.CanSet() {
+		return fmt.Errorf("类型 %s 没有可写字段 %s", target.Type().Name(), name)
+	}
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Type().AssignableTo(field.Type()):
+		field.Set(v)
+	case isNumericKind(v.Kind()) && isNumericKind(field.Kind()):
+		field.Set(v.Convert(field.Type()))
+	default:
+		return fmt.Errorf("字段 %s 类型为 %s，无法赋值 %v (%T)", name, field.Type(), value, value)
+	}
+	return nil
+}
+
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// createAction 创建规则执行动作。
+// assert / retract / modify 的参数在构建时校验，运行时错误仅打印，不中断推理循环。
+// sc 为规则条件的编译结果，用于解析 Data 与 Target 中的事实引用。
+func (b *Builder) createAction(ruleName string, action model.Action, sc *scope) (func(rete.Token), error) {
+	switch action.Type {
+	case "assert", "assert_logical":
+		return b.createAssertAction(ruleName, action, sc)
+	case "retract":
+		return b.createRetractAction(action, sc)
+	case "modify":
+		return b.createModifyAction(action, sc)
+	case "log", "callback", "":
+	default:
+		return nil, fmt.Errorf("不支持的动作类型: %s", action.Type)
+	}
+
+	return func(token rete.Token) {
+		switch action.Type {
+		case "log":
+			fmt.Printf("🔥 规则触发: %s | 事实: %v\n", action.Message, token.Facts)
+		case "callback":
+			// 可以在这里添加自定义回调逻辑
+			fmt.Printf("📞 回调执行: %s\n", action.Message)
+		default:
+			fmt.Printf("⚡ 动作执行: %s\n", action.Message)
+		}
+	}, nil
+}
+
+// createAssertAction 创建插入新事实的动作。
+// assert_logical 以触发规则的 Token 为依据插入逻辑事实，Token 被撤回时事实随之撤回。
+func (b *Builder) createAssertAction(ruleName string, action model.Action, sc *scope) (func(rete.Token), error) {
+	typ, ok := b.factTypes[action.FactType]
+	if !ok {
+		return nil, fmt.Errorf("assert 动作的事实类型 '%s' 未注册", action.FactType)
+	}
+	data, err := compileData(action.Data, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(token rete.Token) {
+		v := reflect.New(elemType(typ))
+		if d, ok := v.Interface().(*model.DynamicFact); ok {
+			d.Type = action.FactType
+		}
+		if err := b.applyData(v.Elem(), data, token); err != nil {
+			fmt.Printf("❌ assert 动作执行失败: %v\n", err)
+			return
+		}
+		if typ.Kind() != reflect.Ptr {
+			v = v.Elem() // 以指针注册的类型插入指针事实，否则插入值
+		}
+		newFact := v.Interface().(model.Fact)
+		if action.Message != "" {
+			fmt.Printf("➕ %s | 插入事实: %v\n", action.Message, newFact)
+		}
+		if action.Type == "assert_logical" {
+			b.wm.AddLogicalFact(newFact, justificationOf(ruleName, token))
+			return
+		}
+		b.wm.AddFact(newFact)
+	}, nil
+}
+
+// createRetractAction 创建撤回匹配事实的动作。
+func (b *Builder) createRetractAction(action model.Action, sc *scope) (func(rete.Token), error) {
+	ref, err := b.parseTarget(action, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(token rete.Token) {
+		val, err := b.resolve(ref, token)
+		if err != nil {
+			fmt.Printf("❌ retract 动作执行失败: %v\n", err)
+			return
+		}
+		fact := val.(model.Fact)
+		if action.Message != "" {
+			fmt.Printf("➖ %s | 撤回事实: %v\n", action.Message, fact)
+		}
+		b.wm.RetractFact(fact)
+	}, nil
+}
+
+// createModifyAction 创建修改匹配事实的动作：复制目标事实、修改字段后通过 UpdateFact 替换原事实。
+// 若修改导致 Key 变化，则撤回原事实并插入新事实；修改后与原事实相同时什么也不做。
+// 触发规则自身的 Token 因修改重新匹配时不会再次激活（见 rete.TerminalNode），避免规则无限循环。
+func (b *Builder) createModifyAction(action model.Action, sc *scope) (func(rete.Token), error) {
+	ref, err := b.parseTarget(action, sc)
+	if err != nil {
+		return nil, err
+	}
+	data, err := compileData(action.Data, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(token rete.Token) {
+		val, err := b.resolve(ref, token)
+		if err != nil {
+			fmt.Printf("❌ modify 动作执行失败: %v\n", err)
+			return
+		}
+		old := val.(model.Fact)
+
+		// 复制后修改，不原地修改工作内存中的事实；指针事实复制其指向的值，得到新指针
+		orig := reflect.Indirect(reflect.ValueOf(val))
+		if d, ok := orig.Interface().(model.DynamicFact); ok {
+			orig = reflect.ValueOf(d.Clone()) // 属性表是引用，先复制再修改
+		}
+		if orig.Kind() != reflect.Struct {
+			fmt.Printf("❌ modify 动作执行失败: 事实 %s 不是结构体\n", old.Key())
+			return
+		}
+		v := reflect.New(orig.Type())
+		v.Elem().Set(orig)
+		if err := b.applyData(v.Elem(), data, token); err != nil {
+			fmt.Printf("❌ modify 动作执行失败: %v\n", err)
+			return
+		}
+		if reflect.TypeOf(old).Kind() != reflect.Ptr {
+			v = v.Elem()
+		}
+		newFact := v.Interface().(model.Fact)
+		if reflect.DeepEqual(newFact, old) {
+			return // 修改后与原事实相同，不触发重新匹配
+		}
+		if action.Message != "" {
+			fmt.Printf("✏️ %s | 修改事实: %v -> %v\n", action.Message, old, newFact)
+		}
+		if newFact.Key() != old.Key() {
+			b.wm.RetractFact(old)
+			b.wm.AddFact(newFact)
+			return
+		}
+		b.wm.UpdateFact(newFact)
+	}, nil
+}
+
+// parseTarget 解析 retract/modify 动作的目标事实引用。
+func (b *Builder) parseTarget(action model.Action, sc *scope) (factRef, error) {
+	if action.Target == "" {
+		return factRef{}, fmt.Errorf("%s 动作需要指定 target，如 \"$0\"", action.Type)
+	}
+	ref, err := sc.parseRef(action.Target)
+	if err != nil {
+		return factRef{}, err
+	}
+	if ref.field != "" {
+		return factRef{}, fmt.Errorf("%s 动作的 target 必须指向事实本身: %s", action.Type, action.Target)
+	}
+	return ref, nil
+}
@@ -1,9 +1,9 @@
 package builder
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
-	"strconv"
 
 	"code_for_article/ruleengine/agenda"
 	"code_for_article/ruleengine/model"
@@ -13,259 +13,609 @@ import (
 // Builder 负责将声明式的规则定义编译成 Rete 网络。
 type Builder struct {
 	alphaNodes map[string]*rete.AlphaNode // key: 条件描述
+	chain      map[string]chainLink       // key: 条件前缀签名，用于共享节点链
+	factTypes  map[string]reflect.Type    // key: 事实类型名，用于 assert 动作创建事实
 	agenda     *agenda.Agenda
+	wm         WorkingMemory
+	clock      rete.Clock         // 时间窗口的时钟
+	windows    []*rete.WindowNode // 已构建的窗口，见 window.go
 }
 
 // NewBuilder 创建一个新的规则构建器。
-func NewBuilder(ag *agenda.Agenda) *Builder {
+// wm 供 assert/retract/modify 动作修改工作内存。
+func NewBuilder(ag *agenda.Agenda, wm WorkingMemory) *Builder {
 	return &Builder{
 		alphaNodes: make(map[string]*rete.AlphaNode),
+		chain:      make(map[string]chainLink),
+		factTypes:  make(map[string]reflect.Type),
 		agenda:     ag,
+		wm:         wm,
+		clock:      rete.SystemClock(),
 	}
 }
 
+// RegisterFactType 以 name 注册事实类型，prototype 为该类型的零值或任意实例。
+// 注册后 assert 动作可按类型名创建事实，表达式也能在加载时检查字段类型。
+func (b *Builder) RegisterFactType(name string, prototype model.Fact) {
+	b.factTypes[name] = reflect.TypeOf(prototype)
+}
+
 // BuildRule 将单条规则编译成 Rete 网络节点，并返回根节点列表。
-// 简化实现：每个条件都创建独立的 AlphaNode，并通过 BetaNode 连接。
+//
+// 条件按顺序编译成一条节点链：第一个条件作为链头（AlphaNode 或 AggregateNode），
+// 后续条件依次接入 BetaNode / NotNode / ExistsNode，链尾连接 TerminalNode。
+// 以 not / exists 开头的规则以初始事实（rete.InitialFact）作为左输入，由引擎负责插入。
+// 与经典 Rete 一样，条件前缀相同的规则共享同一段节点链，公共部分只计算一次。
+//
+// 含 or 条件的规则先展开为若干不含 or 的分支，每个分支各自编译成一条节点链，
+// 所有链尾连接同一个 TerminalNode，由它合并各分支产生的相同 Token。
 func (b *Builder) BuildRule(rule model.Rule) ([]*rete.AlphaNode, error) {
-	var rootNodes []*rete.AlphaNode
-	var currentNode rete.Node
-
-	// 计算规则特殊性（条件数量）
-	specificity := len(rule.When)
-
-	// 创建终端节点
-	terminalNode := rete.NewTerminalNode(rule.Name, b.agenda, b.createAction(rule.Then), rule.Salience, specificity)
-
-	// 简化：处理第一个条件作为根节点
 	if len(rule.When) == 0 {
-		return rootNodes, fmt.Errorf("规则 '%s' 没有条件", rule.Name)
+		return nil, fmt.Errorf("规则 '%s' 没有条件", rule.Name)
+	}
+	branches, err := expandOr(rule.When)
+	if err != nil {
+		return nil, err
 	}
 
-	firstCondition := rule.When[0]
-	switch firstCondition.Type {
-	case "fact":
-		alphaNode, err := b.buildFactCondition(firstCondition)
+	var (
+		rootNodes   []*rete.AlphaNode
+		ends        []rete.Node
+		scopes      []*scope
+		specificity int // 规则特殊性：各分支的最大值
+	)
+	for n, branch := range branches {
+		end, sc, roots, err := b.buildBranch(branch)
 		if err != nil {
+			if len(branches) > 1 {
+				return nil, fmt.Errorf("第 %d 个 OR 分支: %w", n+1, err)
+			}
 			return nil, err
 		}
-		rootNodes = append(rootNodes, alphaNode)
-		currentNode = alphaNode
+		rootNodes = append(rootNodes, roots...)
+		ends = append(ends, end)
+		scopes = append(scopes, sc)
+		specificity = max(specificity, specificityOf(branch))
+	}
 
-	case "aggregate":
-		aggNode := b.buildAggregateNode(firstCondition)
-		// 聚合节点需要包装在 AlphaNode 中作为根节点
-		dummyAlpha := rete.NewAlphaNode(func(f model.Fact) bool { return true })
-		dummyAlpha.AddChild(aggNode)
-		rootNodes = append(rootNodes, dummyAlpha)
-		currentNode = aggNode
+	// 创建终端节点：各分支的事实引用必须一致，动作才能按同一方式解析
+	if err := checkActionRefs(rule.Then, scopes); err != nil {
+		return nil, err
+	}
+	action, err := b.createAction(rule.Name, rule.Then, scopes[0])
+	if err != nil {
+		return nil, err
+	}
+	terminalNode := rete.NewTerminalNode(rule.Name, b.agenda, action, rule.Salience, specificity)
+	if rule.Then.Type == "assert_logical" {
+		// Token 撤回时移除其作为依据支撑的逻辑事实
+		terminalNode.OnRetract(func(tok rete.Token) {
+			b.wm.RemoveJustification(justificationOf(rule.Name, tok))
+		})
+		// 同一次传播中重新匹配（如 UpdateFact 修改了无关字段）时保留依据
+		terminalNode.OnAssert(func(tok rete.Token) {
+			b.wm.KeepJustification(justificationOf(rule.Name, tok))
+		})
+	}
 
-	default:
-		return nil, fmt.Errorf("不支持的根节点类型: %s", firstCondition.Type)
+	// 连接终端节点
+	for _, end := range ends {
+		end.AddChild(terminalNode)
+	}
+
+	return rootNodes, nil
+}
+
+// buildBranch 将一组不含 or 的条件编译成节点链，返回链尾节点、条件的编译结果及根节点。
+func (b *Builder) buildBranch(conditions []model.Condition) (rete.Node, *scope, []*rete.AlphaNode, error) {
+	var (
+		rootNodes   []*rete.AlphaNode
+		currentNode rete.Node
+		path        string // 已编译条件前缀的签名
+		sc          = newScope()
+		// 以 not / exists 开头的链拥有独立的初始事实节点，整条链都不与其他规则共享：
+		// 复用已传播过空 Token 的节点会使新规则收不到初始事实
+		shared = len(conditions) > 0 && !startsWithInitialFact(conditions[0])
+	)
+	for i, condition := range conditions {
+		if i == 0 {
+			path = conditionKey(condition)
+		} else {
+			path += " && " + conditionKey(condition)
+		}
+
+		link, ok := b.chain[path]
+		if !ok || !shared {
+			// 条件前缀不同：编译新节点；否则复用已有节点链
+			var err error
+			link, err = b.buildLink(i, condition, currentNode, sc)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("第 %d 个条件: %w", i+1, err)
+			}
+			if shared {
+				b.chain[path] = link
+			}
+		}
+		rootNodes = append(rootNodes, link.roots...)
+		currentNode = link.node
+
+		// 记录条件在 Token 中的位置及绑定变量
+		if extendsToken(condition) {
+			if err := sc.push(condition.Bind, b.tokenFactType(condition)); err != nil {
+				return nil, nil, nil, fmt.Errorf("第 %d 个条件: %w", i+1, err)
+			}
+		} else if condition.Bind != "" {
+			return nil, nil, nil, fmt.Errorf("第 %d 个条件: %s 条件不产生事实，不能绑定变量 %s", i+1, condition.Type, condition.Bind)
+		}
+	}
+	return currentNode, sc, rootNodes, nil
+}
+
+// startsWithInitialFact 判断作为第一个条件时是否以初始事实作为左输入。
+func startsWithInitialFact(condition model.Condition) bool {
+	return condition.Type == "not" || condition.Type == "exists"
+}
+
+// ResetSharing 使之后构建的规则不再复用此前规则的节点。
+// 已有节点的内存中保存着之前的匹配，新接入的子节点收不到这些匹配；
+// 引擎在工作内存非空时加载规则前调用它，再向新建的节点重放工作内存中的事实。
+func (b *Builder) ResetSharing() {
+	b.alphaNodes = make(map[string]*rete.AlphaNode)
+	b.chain = make(map[string]chainLink)
+}
+
+// tokenFactType 返回条件向 Token 追加的事实的类型，未注册的事实类型返回 nil。
+func (b *Builder) tokenFactType(condition model.Condition) reflect.Type {
+	if condition.Type == "aggregate" {
+		return reflect.TypeOf(rete.AggregateResult{})
+	}
+	return b.factTypes[condition.FactType]
+}
+
+// expandOr 将含 or 条件的条件列表展开为若干不含 or 的分支（析取范式）。
+// 例如 [A, or(B | C), D] 展开为 [A, B, D] 与 [A, C, D]，or 可以嵌套。
+func expandOr(conditions []model.Condition) ([][]model.Condition, error) {
+	branches := [][]model.Condition{nil}
+	for i, condition := range conditions {
+		if condition.Type != "or" {
+			for j := range branches {
+				branches[j] = append(branches[j], condition)
+			}
+			continue
+		}
+
+		if len(condition.Any) == 0 {
+			return nil, fmt.Errorf("第 %d 个条件: or 条件至少需要一个分支", i+1)
+		}
+		if condition.Bind != "" {
+			return nil, fmt.Errorf("第 %d 个条件: or 条件不能绑定变量 %s，请在分支内的条件上绑定", i+1, condition.Bind)
+		}
+		var alternatives [][]model.Condition
+		for k, group := range condition.Any {
+			if len(group) == 0 {
+				return nil, fmt.Errorf("第 %d 个条件: or 条件的第 %d 个分支为空", i+1, k+1)
+			}
+			expanded, err := expandOr(group)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 个条件: or 条件的第 %d 个分支: %w", i+1, k+1, err)
+			}
+			alternatives = append(alternatives, expanded...)
+		}
+
+		next := make([][]model.Condition, 0, len(branches)*len(alternatives))
+		for _, branch := range branches {
+			for _, alternative := range alternatives {
+				combined := append(append([]model.Condition{}, branch...), alternative...)
+				next = append(next, combined)
+			}
+		}
+		branches = next
 	}
+	return branches, nil
+}
 
-	// 处理后续条件（简化：仅支持基本的 fact 条件连接）
-	for i := 1; i < len(rule.When); i++ {
-		condition := rule.When[i]
+// chainLink 是节点链中的一环：条件编译出的节点及其需要注册到引擎的根节点。
+type chainLink struct {
+	node  rete.Node
+	roots []*rete.AlphaNode
+}
 
+// buildLink 编译第 i 个条件并接到 parent 之后，sc 为此前条件的编译结果。
+func (b *Builder) buildLink(i int, condition model.Condition, parent rete.Node, sc *scope) (chainLink, error) {
+	if i == 0 {
 		switch condition.Type {
 		case "fact":
-			alphaNode, err := b.buildFactCondition(condition)
+			tests, _, err := b.compilePattern(condition, sc)
 			if err != nil {
-				return nil, err
+				return chainLink{}, err
 			}
+			alphaNode, root := b.buildFactCondition(condition.FactType, tests)
+			return chainLink{node: alphaNode, roots: []*rete.AlphaNode{root}}, nil
 
-			// 创建 BetaNode 连接
-			betaNode := b.buildJoinNode(condition.Join)
+		case "aggregate":
+			tests, _, err := b.compilePattern(condition, sc)
+			if err != nil {
+				return chainLink{}, err
+			}
+			aggNode, err := b.buildAggregateNode(condition)
+			if err != nil {
+				return chainLink{}, err
+			}
+			// 聚合节点接在条件的 AlphaNode 链（及窗口）之后，只累加类型与字段约束都满足的事实
+			alphaNode, root := b.buildFactCondition(condition.FactType, tests)
+			source, err := b.windowSource(condition, alphaNode)
+			if err != nil {
+				return chainLink{}, err
+			}
+			source.AddChild(rete.NewRightAdapter(aggNode))
+			return chainLink{node: aggNode, roots: []*rete.AlphaNode{root}}, nil
 
-			// 连接网络
-			if alpha, ok := currentNode.(*rete.AlphaNode); ok {
-				alpha.AddChild(betaNode)
+		case "not", "exists":
+			// 没有上游 Token：以接收初始事实的 AlphaNode 作为左输入，经 InitialAdapter 转为空 Token。
+			// 初始事实节点不在规则间共享（见 buildBranch），引擎据此向之后加载的规则补发初始事实。
+			link, err := b.buildJoinLink(condition, sc)
+			if err != nil {
+				return chainLink{}, err
 			}
-			alphaNode.AddChild(betaNode)
-			currentNode = betaNode
-
-		case "not":
-			// 简化：not 节点直接接在当前节点后
-			notNode := b.buildNotNode(condition)
-			currentNode.AddChild(notNode)
-			currentNode = notNode
-
-		case "exists":
-			// 简化：exists 节点直接接在当前节点后
-			existsNode := b.buildExistsNode(condition)
-			currentNode.AddChild(existsNode)
-			currentNode = existsNode
+			initial := rete.NewTypedAlphaNode(rete.InitialFactType, func(f model.Fact) bool { return true })
+			initial.AddChild(rete.NewInitialAdapter(link.node))
+			link.roots = append(link.roots, initial)
+			return link, nil
+
+		default:
+			return chainLink{}, fmt.Errorf("不支持的根节点类型: %s", condition.Type)
 		}
 	}
 
-	// 连接终端节点
-	currentNode.AddChild(terminalNode)
+	switch condition.Type {
+	case "fact", "not", "exists", "aggregate":
+		link, err := b.buildJoinLink(condition, sc)
+		if err != nil {
+			return chainLink{}, err
+		}
+		parent.AddChild(rete.NewLeftAdapter(link.node))
+		return link, nil
 
-	return rootNodes, nil
+	default:
+		return chainLink{}, fmt.Errorf("不支持的条件类型: %s", condition.Type)
+	}
 }
 
-// buildFactCondition 根据条件创建 AlphaNode。
-func (b *Builder) buildFactCondition(condition model.Condition) (*rete.AlphaNode, error) {
-	key := fmt.Sprintf("%s.%s %s %v", condition.FactType, condition.Field, condition.Operator, condition.Value)
+// buildJoinLink 编译条件的 AlphaNode 链与双输入节点，AlphaNode 链作为右输入；
+// 左输入由调用方连接。
+func (b *Builder) buildJoinLink(condition model.Condition, sc *scope) (chainLink, error) {
+	tests, test, err := b.compilePattern(condition, sc)
+	if err != nil {
+		return chainLink{}, err
+	}
+	alphaNode, root := b.buildFactCondition(condition.FactType, tests)
 
-	if node, exists := b.alphaNodes[key]; exists {
-		return node, nil // 节点复用
+	joinNode, err := b.buildJoinNode(condition, test, sc)
+	if err != nil {
+		return chainLink{}, err
+	}
+	source, err := b.windowSource(condition, alphaNode)
+	if err != nil {
+		return chainLink{}, err
 	}
+	source.AddChild(rete.NewRightAdapter(joinNode))
+	return chainLink{node: joinNode, roots: []*rete.AlphaNode{root}}, nil
+}
 
-	alphaFunc := func(f model.Fact) bool {
-		return b.evaluateCondition(f, condition)
+// conditionKey 返回条件的签名，用于识别规则间相同的条件前缀。
+func conditionKey(condition model.Condition) string {
+	if data, err := json.Marshal(condition); err == nil {
+		return string(data)
 	}
+	// 无法序列化（如 YAML 解析出的 map[interface{}]interface{}）时退化为格式化输出
+	return fmt.Sprintf("%+v", condition)
+}
 
-	node := rete.NewAlphaNode(alphaFunc)
-	b.alphaNodes[key] = node
-	return node, nil
+// alphaTest 是模式中只涉及当前事实的一项测试，编译为 AlphaNode 链中的一个节点。
+type alphaTest struct {
+	desc string // 测试的规范描述，描述相同的测试共享 AlphaNode
+	test rete.AlphaFunc
 }
 
-// buildJoinNode 创建 BetaNode 进行条件连接。
-func (b *Builder) buildJoinNode(joinClause *model.JoinClause) *rete.BetaNode {
-	if joinClause == nil {
-		// 默认连接：简单的 AND 关系，不需要特殊条件
-		return rete.NewBetaNode(func(t rete.Token, f model.Fact) bool {
-			return true // 总是成功连接
+// compilePattern 编译条件对事实的测试：字段约束与 expr 中不引用其他事实的合取项
+// 作为 AlphaNode 测试返回；expr 中引用绑定变量的合取项合并为连接测试，没有时为 nil。
+func (b *Builder) compilePattern(condition model.Condition, sc *scope) ([]alphaTest, rete.JoinFunc, error) {
+	var tests []alphaTest
+	for _, constraint := range constraintsOf(condition) {
+		test, err := b.compileConstraint(constraint, b.factTypes[condition.FactType])
+		if err != nil {
+			return nil, nil, err
+		}
+		tests = append(tests, alphaTest{
+			desc: fmt.Sprintf("%s %s %v", constraint.Field, constraint.Operator, constraint.Value),
+			test: test,
 		})
 	}
+	if condition.Expr == "" {
+		return tests, nil, nil
+	}
 
-	return rete.NewBetaNode(func(t rete.Token, f model.Fact) bool {
-		// 实现基于字段的连接逻辑
-		if len(t.Facts) == 0 {
-			return false
+	exprTests, join, err := b.compileExpr(condition, sc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(tests, exprTests...), join, nil
+}
+
+// buildFactCondition 将模式的测试编译成 AlphaNode 链，返回链尾节点与链头（根）节点。
+//
+// 每个测试编译成一个 AlphaNode，按顺序串成一条链，链尾节点的内存即为
+// 通过全部测试的事实。测试前缀相同的模式共享同一段 AlphaNode 链；
+// 只有链头声明事实类型并注册到 RootNode，后续节点从上游接收已通过过滤的事实。
+// 没有任何测试的模式只限定事实类型。
+func (b *Builder) buildFactCondition(factType string, tests []alphaTest) (node, root *rete.AlphaNode) {
+	if len(tests) == 0 {
+		key := factType
+		if node, exists := b.alphaNodes[key]; exists {
+			return node, node // 节点复用
+		}
+		node = rete.NewTypedAlphaNode(factType, func(f model.Fact) bool { return true })
+		b.alphaNodes[key] = node
+		return node, node
+	}
+
+	var key string
+	for i, t := range tests {
+		if i == 0 {
+			key = factType + "." + t.desc
+		} else {
+			key += " && " + t.desc
 		}
-		leftFact := t.Facts[len(t.Facts)-1]
-		leftVal := b.getFieldValue(leftFact, joinClause.LeftField)
-		rightVal := b.getFieldValue(f, joinClause.RightField)
-		return leftVal == rightVal
-	})
+
+		current, exists := b.alphaNodes[key]
+		if !exists {
+			if node == nil {
+				// 链头声明了事实类型，由 RootNode 按类型分派，条件函数无需再检查类型
+				current = rete.NewTypedAlphaNode(factType, t.test)
+			} else {
+				current = rete.NewAlphaNode(t.test)
+				node.AddChild(current)
+			}
+			b.alphaNodes[key] = current
+		}
+		if node == nil {
+			root = current
+		}
+		node = current
+	}
+	return node, root
 }
 
-// buildNotNode 创建 NotNode。
-func (b *Builder) buildNotNode(condition model.Condition) *rete.NotNode {
-	return rete.NewNotNode(func(t rete.Token, f model.Fact) bool {
-		return b.evaluateCondition(f, condition)
-	})
+// constraintsOf 返回条件中的全部字段约束：Field/Operator/Value 在前，Constraints 在后。
+func constraintsOf(condition model.Condition) []model.Constraint {
+	var constraints []model.Constraint
+	if condition.Field != "" {
+		constraints = append(constraints, model.Constraint{
+			Field:    condition.Field,
+			Operator: condition.Operator,
+			Value:    condition.Value,
+		})
+	}
+	return append(constraints, condition.Constraints...)
 }
 
-// buildExistsNode 创建 ExistsNode。
-func (b *Builder) buildExistsNode(condition model.Condition) *rete.ExistsNode {
-	return rete.NewExistsNode(func(t rete.Token, f model.Fact) bool {
-		return b.evaluateCondition(f, condition)
-	})
+// specificityOf 计算规则分支的特殊性：每个字段约束与表达式各计 1，没有约束的条件计 1。
+func specificityOf(conditions []model.Condition) int {
+	specificity := 0
+	for _, condition := range conditions {
+		n := len(constraintsOf(condition))
+		if condition.Expr != "" {
+			n++
+		}
+		specificity += max(1, n)
+	}
+	return specificity
 }
 
-// buildAggregateNode 创建 AggregateNode。
-func (b *Builder) buildAggregateNode(condition model.Condition) *rete.AggregateNode {
-	groupFunc := func(f model.Fact) (string, bool) {
-		val := b.getFieldValue(f, condition.GroupBy)
-		if val != nil {
-			return fmt.Sprintf("%v", val), true
+// buildJoinNode 按条件类型创建双输入节点：fact 为 BetaNode，not 为 NotNode，exists 为 ExistsNode，
+// aggregate 为 AccumulateNode（对每个左侧 Token 聚合与之连接的事实）。
+// 节点的右输入是条件的 AlphaNode，已完成事实类型与字段约束的过滤，
+// 连接测试只需判断右侧事实与左侧 Token 的关系（见 buildJoin）。
+func (b *Builder) buildJoinNode(condition model.Condition, test rete.JoinFunc, sc *scope) (rete.Node, error) {
+	join, index, err := b.buildJoin(condition, test, sc)
+	if err != nil {
+		return nil, err
+	}
+	switch condition.Type {
+	case "not":
+		return rete.NewIndexedNotNode(join, index), nil
+	case "exists":
+		return rete.NewIndexedExistsNode(join, index), nil
+	case "aggregate":
+		spec, err := b.compileAggregate(condition)
+		if err != nil {
+			return nil, err
 		}
-		return "", false
+		return rete.NewIndexedAccumulateNode(spec, join, index), nil
 	}
-	return rete.NewAggregateNode(groupFunc, condition.Threshold)
+	return rete.NewIndexedBetaNode(join, index), nil
 }
 
-// evaluateCondition 评估单个条件是否满足。
-func (b *Builder) evaluateCondition(fact model.Fact, condition model.Condition) bool {
-	// 检查事实类型
-	factType := reflect.TypeOf(fact).Name()
-	if factType != condition.FactType {
-		return false
+// buildJoin 编译连接测试，test 为条件 expr 中引用绑定变量的部分，没有时为 nil。
+// JoinClause.LeftField 可用 "$p.UserID" 引用绑定变量 p 对应的事实，
+// 不带 "$" 时指向 Token 中最后一个事实。
+// 带 JoinClause 的等值连接同时返回按连接字段建立的哈希索引，否则索引为 nil。
+func (b *Builder) buildJoin(condition model.Condition, test rete.JoinFunc, sc *scope) (rete.JoinFunc, *rete.JoinIndex, error) {
+	if test == nil {
+		// 没有表达式连接测试：简单的 AND 关系
+		test = func(t rete.Token, f model.Fact) bool {
+			return true // 总是成功连接
+		}
+	}
+	joinClause := condition.Join
+	if joinClause == nil {
+		return test, nil, nil
 	}
 
-	// 获取字段值
-	fieldValue := b.getFieldValue(fact, condition.Field)
-	if fieldValue == nil {
-		return false
+	left, err := sc.parseField(joinClause.LeftField)
+	if err != nil {
+		return nil, nil, err
+	}
+	getLeft := b.fieldGetter(sc.types[left.index], left.field)
+	getRight := b.fieldGetter(b.factTypes[condition.FactType], joinClause.RightField)
+	leftValue := func(t rete.Token) interface{} {
+		if left.index >= len(t.Facts) {
+			return nil
+		}
+		v, _ := getLeft(t.Facts[left.index])
+		return v
+	}
+	rightValue := func(f model.Fact) interface{} {
+		v, _ := getRight(f)
+		return v
 	}
 
-	// 执行比较操作
-	return b.compareValues(fieldValue, condition.Operator, condition.Value)
+	index := &rete.JoinIndex{
+		Left:  func(t rete.Token) (string, bool) { return valueKey(leftValue(t)) },
+		Right: func(f model.Fact) (string, bool) { return valueKey(rightValue(f)) },
+	}
+	return func(t rete.Token, f model.Fact) bool {
+		// 实现基于字段的连接逻辑
+		leftVal := leftValue(t)
+		return leftVal != nil && valuesEqual(leftVal, rightValue(f)) && test(t, f)
+	}, index, nil
 }
 
-// getFieldValue 使用反射获取结构体字段值。
-func (b *Builder) getFieldValue(fact model.Fact, fieldName string) interface{} {
-	val := reflect.ValueOf(fact)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
+// buildAggregateNode 创建作为第一个条件的 AggregateNode，按 GroupBy 字段分组聚合。
+func (b *Builder) buildAggregateNode(condition model.Condition) (*rete.AggregateNode, error) {
+	spec, err := b.compileAggregate(condition)
+	if err != nil {
+		return nil, err
 	}
-	if val.Kind() != reflect.Struct {
-		return nil
+	if spec.GroupBy, err = b.compileGroupBy(condition); err != nil {
+		return nil, err
 	}
+	return rete.NewAggregateNodeFromSpec(spec), nil
+}
 
-	field := val.FieldByName(fieldName)
-	if !field.IsValid() {
-		return nil
+// compileGroupBy 编译聚合的分组方式，没有 GroupBy 时全部事实为一组。
+// 分组键按 groupKey 归一化，如 UserID 为 7 与 7.0 属于同一组。
+func (b *Builder) compileGroupBy(condition model.Condition) (rete.AggregateFunc, error) {
+	if condition.GroupBy == "" {
+		return func(f model.Fact) (string, bool) { return "", true }, nil
 	}
-	return field.Interface()
+	groupType, err := pathType(b.factTypes[condition.FactType], condition.GroupBy)
+	if err != nil {
+		return nil, fmt.Errorf("group_by: %w", err)
+	}
+	if groupType != nil {
+		switch elemType(groupType).Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return nil, fmt.Errorf("group_by 字段 %s 的类型 %s 不能作为分组键", condition.GroupBy, groupType)
+		}
+	}
+
+	getGroup := b.fieldGetter(b.factTypes[condition.FactType], condition.GroupBy)
+	return func(f model.Fact) (string, bool) {
+		val, _ := getGroup(f)
+		return groupKey(val) // 分组字段为 nil 的事实不参与聚合
+	}, nil
 }
 
-// compareValues 比较两个值。
-func (b *Builder) compareValues(left interface{}, operator string, right interface{}) bool {
-	switch operator {
-	case "==":
-		return left == right
-	case "!=":
-		return left != right
-	case ">":
-		return b.compareNumeric(left, right) > 0
-	case ">=":
-		return b.compareNumeric(left, right) >= 0
-	case "<":
-		return b.compareNumeric(left, right) < 0
-	case "<=":
-		return b.compareNumeric(left, right) <= 0
-	default:
-		return false
+// compileAggregate 编译聚合函数、被聚合字段与结果约束，不含分组方式。
+// 聚合结果通过 ThresholdOperator（默认 ">="）与 Threshold 比较。
+func (b *Builder) compileAggregate(condition model.Condition) (rete.AggregateSpec, error) {
+	function := condition.Aggregate
+	if function == "" {
+		function = "count"
+	}
+	accumulator, ok := rete.LookupAccumulator(function)
+	if !ok {
+		return rete.AggregateSpec{}, fmt.Errorf("不支持的聚合函数: %s", condition.Aggregate)
+	}
+	if function != "count" && condition.AggregateField == "" {
+		return rete.AggregateSpec{}, fmt.Errorf("聚合函数 %s 需要指定 aggregate_field", function)
+	}
+
+	operator := condition.ThresholdOperator
+	if operator == "" {
+		operator = ">="
+	}
+	test, err := b.compileOperator(operator, condition.Threshold, nil)
+	if err != nil {
+		return rete.AggregateSpec{}, fmt.Errorf("聚合结果约束: %w", err)
+	}
+
+	var valueFunc rete.ValueFunc
+	if condition.AggregateField != "" {
+		getValue := b.fieldGetter(b.factTypes[condition.FactType], condition.AggregateField)
+		valueFunc = func(f model.Fact) interface{} {
+			v, _ := getValue(f)
+			return v
+		}
 	}
+
+	return rete.AggregateSpec{
+		Function:    function,
+		Value:       valueFunc,
+		Accumulator: accumulator,
+		Test: func(result float64) bool {
+			return test(result)
+		},
+	}, nil
 }
 
-// compareNumeric 比较数值。
-func (b *Builder) compareNumeric(left, right interface{}) int {
-	leftFloat := b.toFloat64(left)
-	rightFloat := b.toFloat64(right)
+// compileConstraint 将单个字段约束编译为 AlphaFunc，不检查事实类型。
+// factType 为已注册的事实类型，用于在加载时检查字段与值的类型，未注册时为 nil。
+func (b *Builder) compileConstraint(constraint model.Constraint, factType reflect.Type) (rete.AlphaFunc, error) {
+	fieldType, err := pathType(factType, constraint.Field)
+	if err != nil {
+		return nil, err
+	}
 
-	if leftFloat > rightFloat {
-		return 1
-	} else if leftFloat < rightFloat {
-		return -1
+	pred, err := b.compileOperator(constraint.Operator, constraint.Value, fieldType)
+	if err != nil {
+		return nil, fmt.Errorf("字段 %s: %w", constraint.Field, err)
 	}
-	return 0
+	get := b.fieldGetter(factType, constraint.Field)
+	return func(f model.Fact) bool {
+		// 字段不存在时约束不满足；字段值为 nil 交给运算符判断（如 is_null）
+		v, ok := get(f)
+		return ok && pred(v)
+	}, nil
 }
 
-// toFloat64 将值转换为 float64。
-func (b *Builder) toFloat64(val interface{}) float64 {
-	switch v := val.(type) {
-	case int:
-		return float64(v)
-	case int64:
-		return float64(v)
-	case float32:
-		return float64(v)
-	case float64:
-		return v
-	case string:
-		if f, err := strconv.ParseFloat(v, 64); err == nil {
-			return f
+// fieldGetter 返回读取字段路径 field 的函数：路径首段在类型 t 中有 factgen 生成的读取函数时直接使用，
+// 其余部分（以及 t 为 nil 或未生成的情况）使用反射。在编译条件时调用一次，避免每次求值都解析路径。
+func (b *Builder) fieldGetter(t reflect.Type, field string) model.FieldGetter {
+	segs, err := parsePath(field)
+	if err != nil {
+		return func(model.Fact) (any, bool) { return nil, false }
+	}
+
+	dynamic := t != nil && t.Implements(attributeFactType) // 首段是属性而不是结构体字段
+	if getter, ok := model.LookupAccessor(t, segs[0].name); ok && !dynamic {
+		if len(segs) == 1 {
+			return getter
+		}
+		rest := segs[1:]
+		return func(f model.Fact) (any, bool) {
+			v, ok := getter(f)
+			if !ok {
+				return nil, false
+			}
+			return walkPath(reflect.ValueOf(v), rest)
 		}
 	}
-	return 0
+	return func(f model.Fact) (any, bool) { return walkFact(f, segs) }
 }
 
-// createAction 创建规则执行动作。
-func (b *Builder) createAction(action model.Action) func(rete.Token) {
-	return func(token rete.Token) {
-		switch action.Type {
-		case "log":
-			fmt.Printf("🔥 规则触发: %s | 事实: %v\n", action.Message, token.Facts)
-		case "callback":
-			// 可以在这里添加自定义回调逻辑
-			fmt.Printf("📞 回调执行: %s\n", action.Message)
-		default:
-			fmt.Printf("⚡ 动作执行: %s\n", action.Message)
-		}
+// getFieldValue 使用反射获取字段路径的值，路径无效时返回 nil。
+func (b *Builder) getFieldValue(fact model.Fact, fieldName string) interface{} {
+	v, _ := b.fieldValue(fact, fieldName)
+	return v
+}
+
+// fieldValue 使用反射获取字段路径的值，ok 表示路径对该事实是否有效。
+func (b *Builder) fieldValue(fact model.Fact, fieldName string) (v interface{}, ok bool) {
+	segs, err := parsePath(fieldName)
+	if err != nil {
+		return nil, false
 	}
+	return walkFact(fact, segs)
 }
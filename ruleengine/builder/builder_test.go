@@ -0,0 +1,75 @@
+package builder
+
+import (
+	"testing"
+
+	"code_for_article/ruleengine/agenda"
+	"code_for_article/ruleengine/model"
+	"code_for_article/ruleengine/rete"
+)
+
+func TestBuildRuleSharesConditionPrefix(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	vipCart := []model.Condition{
+		{Type: "fact", FactType: "User", Field: "Level", Operator: "==", Value: "VIP"},
+		{Type: "fact", FactType: "Cart", Field: "TotalValue", Operator: ">", Value: 100,
+			Join: &model.JoinClause{LeftField: "ID", RightField: "UserID"}},
+	}
+
+	ruleA := model.Rule{Name: "A", When: vipCart, Then: model.Action{Type: "log"}}
+	ruleB := model.Rule{
+		Name: "B",
+		When: append(append([]model.Condition{}, vipCart...),
+			model.Condition{Type: "fact", FactType: "Cart", Field: "TotalValue", Operator: ">", Value: 500,
+				Join: &model.JoinClause{LeftField: "ID", RightField: "UserID"}}),
+		Then: model.Action{Type: "log"},
+	}
+
+	if _, err := b.BuildRule(ruleA); err != nil {
+		t.Fatalf("构建规则 A 失败: %v", err)
+	}
+	joinA := b.chain[conditionKey(vipCart[0])+" && "+conditionKey(vipCart[1])].node
+	if _, err := b.BuildRule(ruleB); err != nil {
+		t.Fatalf("构建规则 B 失败: %v", err)
+	}
+	joinB := b.chain[conditionKey(vipCart[0])+" && "+conditionKey(vipCart[1])].node
+
+	if joinA != joinB {
+		t.Fatalf("相同条件前缀应共享同一个 BetaNode")
+	}
+	// A: User、User&&Cart；B 额外增加一环
+	if len(b.chain) != 3 {
+		t.Fatalf("期望节点链共 3 环，实际 %d", len(b.chain))
+	}
+}
+
+func TestFactConditionSharesConstraintChain(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	vip := model.Condition{Type: "fact", FactType: "User", Field: "Level", Operator: "==", Value: "VIP"}
+	vipNormal := vip
+	vipNormal.Constraints = []model.Constraint{{Field: "Status", Operator: "==", Value: "normal"}}
+
+	build := func(condition model.Condition) (node, root *rete.AlphaNode) {
+		tests, _, err := b.compilePattern(condition, newScope())
+		if err != nil {
+			t.Fatalf("编译条件失败: %v", err)
+		}
+		return b.buildFactCondition(condition.FactType, tests)
+	}
+
+	node, root := build(vipNormal)
+	vipNode, vipRoot := build(vip)
+	if vipNode != root || vipRoot != root {
+		t.Fatalf("相同的首个约束应共享链头 AlphaNode")
+	}
+	if node == root {
+		t.Fatalf("多约束模式应在链头之后追加 AlphaNode")
+	}
+	if node.FactType() != "" {
+		t.Fatalf("链头之后的节点不应注册事实类型，实际 %q", node.FactType())
+	}
+
+	if got := specificityOf([]model.Condition{vipNormal}); got != 2 {
+		t.Fatalf("期望特殊性 2，实际 %d", got)
+	}
+}
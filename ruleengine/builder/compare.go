@@ -0,0 +1,286 @@
+package builder
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// compare.go 是条件中所有比较共用的值比较层。
+//
+// 事实字段与 YAML/JSON 字面量的 Go 类型往往不同（如 YAML 的 100 解析为 int，Amount 是 float64），
+// 比较前统一归一化：
+//   - 数值：任意整数、浮点类型按数值比较，都是整数时精确比较；
+//   - 字符串：包括以 string 为底层类型的自定义类型；
+//   - 布尔值：只比较是否相等；
+//   - 时间：time.Time 之间比较先后，字符串按 RFC3339 或 "2006-01-02" 解析为时间。
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// timeLayouts 是字符串转换为时间时依次尝试的格式。
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// compareValues 按运算符比较两个值，不可比较的值只在 "!=" 时返回 true。
+func (b *Builder) compareValues(left interface{}, operator string, right interface{}) bool {
+	switch operator {
+	case "==":
+		return valuesEqual(left, right)
+	case "!=":
+		return !valuesEqual(left, right)
+	}
+
+	order, ok := compareOrder(left, right)
+	if !ok {
+		return false
+	}
+	switch operator {
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	default:
+		return false // 运算符已在 compileOperator 中校验
+	}
+}
+
+// valuesEqual 判断两个值归一化后是否相等，无法归一化的值按 reflect.DeepEqual 比较。
+func valuesEqual(left, right interface{}) bool {
+	if order, ok := compareOrder(left, right); ok {
+		return order == 0
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// compareOrder 比较两个值的大小，返回 -1、0、1；ok 为 false 表示两者不可比较。
+func compareOrder(left, right interface{}) (order int, ok bool) {
+	if left == nil || right == nil {
+		return 0, left == nil && right == nil
+	}
+
+	if li, lok := integerOf(left); lok {
+		if ri, rok := integerOf(right); rok {
+			return cmp.Compare(li, ri), true
+		}
+	}
+	if l, lok := numberOf(left); lok {
+		if r, rok := numberOf(right); rok {
+			return cmp.Compare(l, r), true
+		}
+		return 0, false
+	}
+
+	lt, lok := timeOf(left)
+	rt, rok := timeOf(right)
+	if lok || rok {
+		// 一侧是时间时，另一侧的字符串按时间解析
+		if !lok {
+			lt, lok = parseTimeValue(left)
+		}
+		if !rok {
+			rt, rok = parseTimeValue(right)
+		}
+		if !lok || !rok {
+			return 0, false
+		}
+		return lt.Compare(rt), true
+	}
+
+	if ls, lok := stringOf(left); lok {
+		if rs, rok := stringOf(right); rok {
+			return strings.Compare(ls, rs), true
+		}
+		return 0, false
+	}
+
+	if lb, lok := left.(bool); lok {
+		if rb, rok := right.(bool); rok {
+			return cmp.Compare(boolOrder(lb), boolOrder(rb)), true
+		}
+	}
+	return 0, false
+}
+
+// numberOf 将任意数值类型转换为 float64。
+func numberOf(v interface{}) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// integerOf 将可无损表示为 int64 的整数类型转换为 int64，避免大整数经 float64 比较时丢失精度。
+func integerOf(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u), true
+		}
+	}
+	return 0, false
+}
+
+// stringOf 返回字符串或以 string 为底层类型的值。
+func stringOf(v interface{}) (string, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.String {
+		return "", false
+	}
+	return rv.String(), true
+}
+
+// timeOf 返回 time.Time 或 *time.Time 的时间值。
+func timeOf(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case *time.Time:
+		if t != nil {
+			return *t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseTimeValue 将字符串按 timeLayouts 解析为时间。
+func parseTimeValue(v interface{}) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	return parseTime(s)
+}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func boolOrder(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// valueKey 将值转换为哈希索引的键。索引只是预过滤（见 rete.JoinIndex），
+// 只需保证 valuesEqual 判定相等的值得到相同的键，不相等的值允许共用一个键：
+//   - 数值统一按 float64 取键，因为整数与浮点数之间按 float64 比较；超过 2^53 的相邻整数会共用键；
+//   - 可解析为时间的字符串按时间取键，与相等的 time.Time 的键一致。
+func valueKey(v interface{}) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	if n, ok := numberOf(v); ok {
+		return strconv.FormatFloat(n, 'g', -1, 64), true
+	}
+	if t, ok := timeOf(v); ok {
+		return t.UTC().Format(time.RFC3339Nano), true
+	}
+	if s, ok := stringOf(v); ok {
+		if t, ok := parseTime(s); ok {
+			return t.UTC().Format(time.RFC3339Nano), true
+		}
+		return s, true
+	}
+	return fmt.Sprint(v), true
+}
+
+// groupKey 将 group_by 字段的值转换为分组键。与 valueKey 不同，分组键决定哪些事实属于同一组，
+// 因此不能让不相等的值共用：整数精确取键，整数值的浮点数与对应整数同组（如 7 与 7.0），
+// 时间按 UTC 取键，字符串原样作为键。
+func groupKey(v interface{}) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	if n, ok := numberOf(v); ok {
+		if i, ok := integerOf(v); ok {
+			return strconv.FormatInt(i, 10), true
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), true
+	}
+	if t, ok := timeOf(v); ok {
+		return t.UTC().Format(time.RFC3339Nano), true
+	}
+	if s, ok := stringOf(v); ok {
+		return s, true
+	}
+	return fmt.Sprint(v), true
+}
+
+// coerceValue 在加载规则时按字段类型检查并转换字面量：
+// 时间字段接受可解析的时间字符串，time.Duration 字段接受如 "5m" 的时长字符串；
+// 类型不兼容时返回错误，而不是让条件在运行时永远不满足。
+func coerceValue(fieldType reflect.Type, value interface{}) (interface{}, error) {
+	for fieldType.Kind() == reflect.Ptr && fieldType != timeType {
+		fieldType = fieldType.Elem()
+	}
+
+	switch {
+	case fieldType == timeType:
+		if _, ok := timeOf(value); ok {
+			return value, nil
+		}
+		if s, ok := value.(string); ok {
+			if t, ok := parseTime(s); ok {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("时间字段不能与 %v (%T) 比较，字符串需使用 RFC3339 或 2006-01-02 格式", value, value)
+
+	case fieldType == durationType:
+		if s, ok := value.(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, fmt.Errorf("无效的时长 %q: %w", s, err)
+			}
+			return d, nil
+		}
+		if _, ok := numberOf(value); ok {
+			return value, nil
+		}
+		return nil, fmt.Errorf("时长字段不能与 %v (%T) 比较", value, value)
+	}
+
+	var ok bool
+	switch typeOfKind(fieldType) {
+	case typeNumber:
+		_, ok = numberOf(value)
+	case typeString:
+		_, ok = stringOf(value)
+	case typeBool:
+		_, ok = value.(bool)
+	default:
+		return value, nil // 其他类型在运行时比较
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s字段不能与 %v (%T) 比较", typeOfKind(fieldType), value, value)
+	}
+	return value, nil
+}
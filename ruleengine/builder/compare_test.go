@@ -0,0 +1,108 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"code_for_article/ruleengine/agenda"
+	"code_for_article/ruleengine/model"
+)
+
+type level string
+
+func TestCompareValuesNormalizesTypes(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		left     interface{}
+		operator string
+		right    interface{}
+		want     bool
+	}{
+		{100.0, "==", 100, true},
+		{int64(100), "==", 100.0, true},
+		{uint8(7), "<", 8, true},
+		{int64(1<<62 + 1), "!=", int64(1 << 62), true},
+		{level("VIP"), "==", "VIP", true},
+		{"abc", "<", "abd", true},
+		{true, "==", true, true},
+		{true, "!=", false, true},
+		{day, "==", "2024-05-01", true},
+		{day, "<", "2024-05-01T08:00:00Z", true},
+		{day.Add(time.Hour), ">", day, true},
+		{100, "==", "100", false},
+		{100, "!=", "100", true},
+		{100, ">", "50", false},
+	}
+	for _, c := range cases {
+		if got := b.compareValues(c.left, c.operator, c.right); got != c.want {
+			t.Fatalf("%v (%T) %s %v (%T) 期望 %v，实际 %v", c.left, c.left, c.operator, c.right, c.right, c.want, got)
+		}
+	}
+
+	// valuesEqual 判定相等的值必须得到相同的索引键
+	for _, pair := range [][2]interface{}{
+		{100, 100.0},
+		{int64(9007199254740993), 9.007199254740992e15},
+		{day, "2024-05-01"},
+		{day, "2024-05-01T08:00:00+08:00"},
+	} {
+		if !valuesEqual(pair[0], pair[1]) {
+			t.Fatalf("%v 与 %v 应相等", pair[0], pair[1])
+		}
+		if k1, k2 := mustKey(pair[0]), mustKey(pair[1]); k1 != k2 {
+			t.Fatalf("相等的值 %v (%T) 与 %v (%T) 应得到相同的索引键，实际 %s 与 %s", pair[0], pair[0], pair[1], pair[1], k1, k2)
+		}
+	}
+}
+
+func mustKey(v interface{}) string {
+	k, _ := valueKey(v)
+	return k
+}
+
+type scheduled struct {
+	At      time.Time
+	Timeout time.Duration
+	Amount  float64
+	Paid    bool
+}
+
+func (s scheduled) Key() string { return "scheduled" }
+
+func TestCompileConstraintChecksFieldType(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	factType := reflect.TypeOf(scheduled{})
+	fact := scheduled{At: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), Timeout: 10 * time.Minute, Amount: 100}
+
+	for _, c := range []model.Constraint{
+		{Field: "At", Operator: ">", Value: "2024-05-01"},
+		{Field: "Timeout", Operator: ">=", Value: "5m"},
+		{Field: "Amount", Operator: "==", Value: 100},
+		{Field: "Amount", Operator: "in", Value: []interface{}{50, 100}},
+		{Field: "Paid", Operator: "==", Value: false},
+	} {
+		test, err := b.compileConstraint(c, factType)
+		if err != nil {
+			t.Fatalf("%+v 编译失败: %v", c, err)
+		}
+		if !test(fact) {
+			t.Fatalf("%+v 期望满足", c)
+		}
+	}
+
+	for _, c := range []model.Constraint{
+		{Field: "Amount", Operator: "==", Value: "100"},
+		{Field: "Amount", Operator: "in", Value: []interface{}{50, "100"}},
+		{Field: "At", Operator: ">", Value: "昨天"},
+		{Field: "Timeout", Operator: ">", Value: "五分钟"},
+		{Field: "Paid", Operator: ">", Value: false},
+		{Field: "Amount", Operator: "starts_with", Value: "1"},
+		{Field: "Missing", Operator: "==", Value: 1},
+	} {
+		if _, err := b.compileConstraint(c, factType); err == nil {
+			t.Fatalf("期望 %+v 编译失败", c)
+		}
+	}
+}
@@ -0,0 +1,824 @@
+package builder
+
+// expr.go 实现条件表达式（Condition.Expr）的词法分析、语法分析、类型检查与编译。
+//
+// 语法（优先级由低到高）:
+//
+//	or             = and { "||" and }
+//	and            = not { "&&" not }
+//	not            = "!" not | comparison
+//	comparison     = additive [ ( "==" | "!=" | ">" | ">=" | "<" | "<=" | "in" | "not" "in" ) additive ]
+//	additive       = multiplicative { ( "+" | "-" ) multiplicative }
+//	multiplicative = unary { ( "*" | "/" | "%" ) unary }
+//	unary          = "-" unary | primary
+//	primary        = number | string | "true" | "false" | field | ref | list | "(" or ")"
+//	field          = ident path             当前条件事实的字段路径，如 Amount、Payload.amount、Items[0].Price
+//	ref            = "$" ident path         绑定变量对应事实的字段路径，如 $acct.Balance
+//	path           = { "." ident | "[" number "]" }
+//	list           = "[" [ or { "," or } ] "]"
+//
+// 表达式在加载规则时完成解析与类型检查，并编译为闭包：
+// 不引用其他事实的合取项在 AlphaNode 中求值，引用绑定变量的合取项作为连接测试在 BetaNode 中求值。
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"code_for_article/ruleengine/model"
+	"code_for_article/ruleengine/rete"
+)
+
+// ---------- 词法分析 ----------
+
+type exprTokenKind int
+
+const (
+	tokEOF    exprTokenKind = iota
+	tokNumber               // 123、4.5
+	tokString               // "USD"、'USD'
+	tokIdent                // Amount、in、true
+	tokRef                  // $acct、$0
+	tokOp                   // 运算符与标点
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int // 在源串中的字节偏移，用于错误信息
+}
+
+// lexExpr 将表达式源串切分为词法单元。
+func lexExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(src)
+	offset := func(i int) int { return len(string(runes[:i])) }
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{tokNumber, string(runes[start:i]), offset(start)})
+
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("位置 %d: 字符串缺少结束引号", offset(start))
+			}
+			i++
+			tokens = append(tokens, exprToken{tokString, string(runes[start:i]), offset(start)})
+
+		case r == '$' || r == '_' || unicode.IsLetter(r):
+			start := i
+			i++
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			kind := tokIdent
+			if r == '$' {
+				kind = tokRef
+				if i == start+1 {
+					return nil, fmt.Errorf("位置 %d: $ 之后缺少变量名", offset(start))
+				}
+			}
+			tokens = append(tokens, exprToken{kind, string(runes[start:i]), offset(start)})
+
+		default:
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", ">=", "<=", "&&", "||":
+					tokens = append(tokens, exprToken{tokOp, two, offset(i)})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("+-*/%<>!()[],.", r) {
+				return nil, fmt.Errorf("位置 %d: 无法识别的字符 %q", offset(i), r)
+			}
+			tokens = append(tokens, exprToken{tokOp, string(r), offset(i)})
+			i++
+		}
+	}
+	return append(tokens, exprToken{kind: tokEOF, pos: len(src)}), nil
+}
+
+// ---------- 语法树 ----------
+
+// exprNode 是表达式语法树的节点，String 返回规范化的表达式文本，用于共享 AlphaNode。
+type exprNode interface {
+	String() string
+}
+
+type literalExpr struct{ value interface{} } // float64、string 或 bool
+
+type listExpr struct{ items []exprNode }
+
+type fieldExpr struct{ name string } // 当前条件事实的字段
+
+type refExpr struct{ ref string } // 绑定变量引用，如 "$acct.Balance"
+
+type unaryExpr struct {
+	op string
+	x  exprNode
+}
+
+type binaryExpr struct {
+	op          string
+	left, right exprNode
+}
+
+func (e literalExpr) String() string {
+	switch v := e.value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(e.value)
+}
+
+func (e listExpr) String() string {
+	items := make([]string, len(e.items))
+	for i, item := range e.items {
+		items[i] = item.String()
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+func (e fieldExpr) String() string { return e.name }
+func (e refExpr) String() string   { return e.ref }
+func (e unaryExpr) String() string { return e.op + e.x.String() }
+func (e binaryExpr) String() string {
+	return "(" + e.left.String() + " " + e.op + " " + e.right.String() + ")"
+}
+
+// hasRefs 判断表达式是否引用了 Token 中的其他事实。
+func hasRefs(n exprNode) bool {
+	switch e := n.(type) {
+	case refExpr:
+		return true
+	case listExpr:
+		for _, item := range e.items {
+			if hasRefs(item) {
+				return true
+			}
+		}
+	case unaryExpr:
+		return hasRefs(e.x)
+	case binaryExpr:
+		return hasRefs(e.left) || hasRefs(e.right)
+	}
+	return false
+}
+
+// conjuncts 将顶层的 && 展开为合取项列表。
+func conjuncts(n exprNode) []exprNode {
+	if e, ok := n.(binaryExpr); ok && e.op == "&&" {
+		return append(conjuncts(e.left), conjuncts(e.right)...)
+	}
+	return []exprNode{n}
+}
+
+// ---------- 语法分析 ----------
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+// parseExpr 解析表达式源串，返回语法树。
+func parseExpr(src string) (exprNode, error) {
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("位置 %d: 多余的内容 %q", tok.pos, tok.text)
+	}
+	return n, nil
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.pos] }
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept 在下一个词法单元是运算符 op 时消费它。
+func (p *exprParser) accept(op string) bool {
+	if tok := p.peek(); tok.kind == tokOp && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		tok := p.peek()
+		return fmt.Errorf("位置 %d: 期望 %q，实际 %q", tok.pos, op, tok.text)
+	}
+	return nil
+}
+
+// parseBinary 解析左结合的二元运算，ops 为本层的运算符，operand 解析更高优先级的一层。
+func (p *exprParser) parseBinary(operand func() (exprNode, error), ops ...string) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		matched := ""
+		for _, op := range ops {
+			if p.accept(op) {
+				matched = op
+				break
+			}
+		}
+		if matched == "" {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: matched, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseOr() (exprNode, error) { return p.parseBinary(p.parseAnd, "||") }
+
+func (p *exprParser) parseAnd() (exprNode, error) { return p.parseBinary(p.parseNot, "&&") }
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.accept("!") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{op: "!", x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	op := ""
+	switch {
+	case tok.kind == tokOp && strings.Contains(" == != > >= < <= ", " "+tok.text+" "):
+		op = tok.text
+		p.next()
+	case tok.kind == tokIdent && tok.text == "in":
+		op = "in"
+		p.next()
+	case tok.kind == tokIdent && tok.text == "not":
+		p.next()
+		if in := p.next(); in.kind != tokIdent || in.text != "in" {
+			return nil, fmt.Errorf("位置 %d: not 之后期望 in，实际 %q", in.pos, in.text)
+		}
+		op = "not in"
+	default:
+		return left, nil
+	}
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return binaryExpr{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.accept("-") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{op: "-", x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePath 解析字段名之后的 ".Field" 与 "[n]"，返回完整的字段路径。
+func (p *exprParser) parsePath(path string) (string, error) {
+	for {
+		switch {
+		case p.accept("."):
+			field := p.next()
+			if field.kind != tokIdent {
+				return "", fmt.Errorf("位置 %d: %s. 之后期望字段名，实际 %q", field.pos, path, field.text)
+			}
+			path += "." + field.text
+		case p.accept("["):
+			index := p.next()
+			if n, err := strconv.Atoi(index.text); index.kind != tokNumber || err != nil || n < 0 {
+				return "", fmt.Errorf("位置 %d: %s[ 之后期望非负整数下标，实际 %q", index.pos, path, index.text)
+			}
+			path += "[" + index.text + "]"
+			if err := p.expect("]"); err != nil {
+				return "", err
+			}
+		default:
+			return path, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d: 无效的数字 %s", tok.pos, tok.text)
+		}
+		return literalExpr{value: v}, nil
+
+	case tokString:
+		return literalExpr{value: unquote(tok.text)}, nil
+
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return literalExpr{value: tok.text == "true"}, nil
+		case "in", "not":
+			return nil, fmt.Errorf("位置 %d: %s 是保留字，不能作为字段名", tok.pos, tok.text)
+		}
+		name, err := p.parsePath(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		return fieldExpr{name: name}, nil
+
+	case tokRef:
+		ref, err := p.parsePath(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		return refExpr{ref: ref}, nil
+
+	case tokOp:
+		switch tok.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			var items []exprNode
+			if p.accept("]") {
+				return listExpr{}, nil
+			}
+			for {
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if p.accept("]") {
+					return listExpr{items: items}, nil
+				}
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("位置 %d: 表达式不完整", tok.pos)
+	}
+	return nil, fmt.Errorf("位置 %d: 意外的 %q", tok.pos, tok.text)
+}
+
+// unquote 去掉字符串字面量的引号并处理转义。
+func unquote(text string) string {
+	if text[0] == '"' {
+		if s, err := strconv.Unquote(text); err == nil {
+			return s
+		}
+	}
+	body := text[1 : len(text)-1]
+	return strings.NewReplacer(`\'`, `'`, `\"`, `"`, `\\`, `\`).Replace(body)
+}
+
+// ---------- 类型检查与编译 ----------
+
+// exprType 是表达式在加载规则时推断出的静态类型。
+type exprType int
+
+const (
+	typeUnknown exprType = iota // 运行时才能确定，如未注册事实类型的字段
+	typeNumber
+	typeString
+	typeBool
+	typeList
+)
+
+func (t exprType) String() string {
+	return [...]string{"未知类型", "数值", "字符串", "布尔值", "列表"}[t]
+}
+
+// is 判断静态类型是否可能为 want：未知类型与任何类型兼容。
+func (t exprType) is(want exprType) bool { return t == typeUnknown || t == want }
+
+// exprFunc 在运行时对表达式求值，ok 为 false 表示无法求值（如字段缺失、除数为零），
+// 此时条件视为不满足。
+type exprFunc func(t rete.Token, f model.Fact) (v interface{}, ok bool)
+
+// exprCompiler 将语法树编译为 exprFunc。
+type exprCompiler struct {
+	b        *Builder
+	sc       *scope       // 此前条件的编译结果，用于解析绑定变量
+	factType reflect.Type // 当前条件的事实类型，未注册时为 nil
+}
+
+// compileExpr 编译条件中的 expr：不引用其他事实的合取项返回为 AlphaNode 测试，
+// 引用绑定变量的合取项合并为连接测试，没有时为 nil。
+func (b *Builder) compileExpr(condition model.Condition, sc *scope) ([]alphaTest, rete.JoinFunc, error) {
+	root, err := parseExpr(condition.Expr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析表达式 %q 失败: %w", condition.Expr, err)
+	}
+
+	c := &exprCompiler{b: b, sc: sc, factType: b.factTypes[condition.FactType]}
+	var (
+		tests []alphaTest
+		joins []exprFunc
+	)
+	for _, n := range conjuncts(root) {
+		fn, typ, err := c.compile(n)
+		if err != nil {
+			return nil, nil, fmt.Errorf("表达式 %q: %w", condition.Expr, err)
+		}
+		if !typ.is(typeBool) {
+			return nil, nil, fmt.Errorf("表达式 %q: %s 的结果必须是布尔值，实际为 %s", condition.Expr, n, typ)
+		}
+
+		if hasRefs(n) {
+			joins = append(joins, fn)
+			continue
+		}
+		tests = append(tests, alphaTest{
+			desc: "expr " + n.String(),
+			test: func(f model.Fact) bool { return isTrue(fn(rete.Token{}, f)) },
+		})
+	}
+
+	if len(joins) == 0 {
+		return tests, nil, nil
+	}
+	join := func(t rete.Token, f model.Fact) bool {
+		for _, fn := range joins {
+			if !isTrue(fn(t, f)) {
+				return false
+			}
+		}
+		return true
+	}
+	return tests, join, nil
+}
+
+func isTrue(v interface{}, ok bool) bool {
+	b, isBool := v.(bool)
+	return ok && isBool && b
+}
+
+func (c *exprCompiler) compile(n exprNode) (exprFunc, exprType, error) {
+	switch e := n.(type) {
+	case literalExpr:
+		return func(rete.Token, model.Fact) (interface{}, bool) { return e.value, true }, typeOfValue(e.value), nil
+
+	case listExpr:
+		items := make([]exprFunc, len(e.items))
+		for i, item := range e.items {
+			fn, _, err := c.compile(item)
+			if err != nil {
+				return nil, typeUnknown, err
+			}
+			items[i] = fn
+		}
+		return func(t rete.Token, f model.Fact) (interface{}, bool) {
+			values := make([]interface{}, len(items))
+			for i, fn := range items {
+				v, ok := fn(t, f)
+				if !ok {
+					return nil, false
+				}
+				values[i] = v
+			}
+			return values, true
+		}, typeList, nil
+
+	case fieldExpr:
+		typ, err := fieldType(c.factType, e.name)
+		if err != nil {
+			return nil, typeUnknown, err
+		}
+		get := c.b.fieldGetter(c.factType, e.name)
+		return func(t rete.Token, f model.Fact) (interface{}, bool) {
+			v, ok := get(f)
+			return v, ok && v != nil
+		}, typ, nil
+
+	case refExpr:
+		ref, err := c.sc.parseRef(e.ref)
+		if err != nil {
+			return nil, typeUnknown, err
+		}
+		if ref.field == "" {
+			return nil, typeUnknown, fmt.Errorf("表达式中的事实引用必须指定字段: %s", e.ref)
+		}
+		typ, err := fieldType(c.sc.types[ref.index], ref.field)
+		if err != nil {
+			return nil, typeUnknown, err
+		}
+		get := c.b.fieldGetter(c.sc.types[ref.index], ref.field)
+		return func(t rete.Token, f model.Fact) (interface{}, bool) {
+			if ref.index >= len(t.Facts) {
+				return nil, false
+			}
+			v, ok := get(t.Facts[ref.index])
+			return v, ok && v != nil
+		}, typ, nil
+
+	case unaryExpr:
+		return c.compileUnary(e)
+
+	case binaryExpr:
+		return c.compileBinary(e)
+	}
+	return nil, typeUnknown, fmt.Errorf("不支持的表达式: %s", n)
+}
+
+func (c *exprCompiler) compileUnary(e unaryExpr) (exprFunc, exprType, error) {
+	x, typ, err := c.compile(e.x)
+	if err != nil {
+		return nil, typeUnknown, err
+	}
+
+	if e.op == "!" {
+		if !typ.is(typeBool) {
+			return nil, typeUnknown, fmt.Errorf("运算符 ! 不能用于%s: %s", typ, e.x)
+		}
+		return func(t rete.Token, f model.Fact) (interface{}, bool) {
+			v, ok := x(t, f)
+			b, isBool := v.(bool)
+			return !b, ok && isBool
+		}, typeBool, nil
+	}
+
+	if !typ.is(typeNumber) {
+		return nil, typeUnknown, fmt.Errorf("运算符 - 不能用于%s: %s", typ, e.x)
+	}
+	return func(t rete.Token, f model.Fact) (interface{}, bool) {
+		v, ok := x(t, f)
+		n, isNumber := numberOf(v)
+		return -n, ok && isNumber
+	}, typeNumber, nil
+}
+
+func (c *exprCompiler) compileBinary(e binaryExpr) (exprFunc, exprType, error) {
+	left, lt, err := c.compile(e.left)
+	if err != nil {
+		return nil, typeUnknown, err
+	}
+	right, rt, err := c.compile(e.right)
+	if err != nil {
+		return nil, typeUnknown, err
+	}
+	mismatch := func() error {
+		return fmt.Errorf("运算符 %s 不能用于%s与%s: %s", e.op, lt, rt, e)
+	}
+
+	switch e.op {
+	case "&&", "||":
+		if !lt.is(typeBool) || !rt.is(typeBool) {
+			return nil, typeUnknown, mismatch()
+		}
+		// 短路求值：&& 左侧为 false、|| 左侧为 true 时不再计算右侧
+		shortCircuit := e.op == "||"
+		return func(t rete.Token, f model.Fact) (interface{}, bool) {
+			v, ok := left(t, f)
+			b, isBool := v.(bool)
+			if !ok || !isBool {
+				return nil, false
+			}
+			if b == shortCircuit {
+				return b, true
+			}
+			v, ok = right(t, f)
+			b, isBool = v.(bool)
+			return b, ok && isBool
+		}, typeBool, nil
+
+	case "+", "-", "*", "/", "%":
+		var typ exprType
+		switch {
+		case lt.is(typeNumber) && rt.is(typeNumber):
+			typ = typeNumber
+		case e.op == "+" && lt.is(typeString) && rt.is(typeString):
+			typ = typeString
+		default:
+			return nil, typeUnknown, mismatch()
+		}
+		if lt == typeUnknown || rt == typeUnknown {
+			typ = typeUnknown
+		}
+		return func(t rete.Token, f model.Fact) (interface{}, bool) {
+			lv, lok := left(t, f)
+			rv, rok := right(t, f)
+			if !lok || !rok {
+				return nil, false
+			}
+			return arithmetic(e.op, lv, rv)
+		}, typ, nil
+
+	case "==", "!=", ">", ">=", "<", "<=":
+		if lt != typeUnknown && rt != typeUnknown && lt != rt {
+			return nil, typeUnknown, mismatch()
+		}
+		if e.op != "==" && e.op != "!=" && !(lt.is(typeNumber) && rt.is(typeNumber)) && !(lt.is(typeString) && rt.is(typeString)) {
+			return nil, typeUnknown, mismatch()
+		}
+		return func(t rete.Token, f model.Fact) (interface{}, bool) {
+			lv, lok := left(t, f)
+			rv, rok := right(t, f)
+			if !lok || !rok {
+				return nil, false
+			}
+			return compareExprValues(e.op, lv, rv)
+		}, typeBool, nil
+
+	case "in", "not in":
+		if !rt.is(typeList) {
+			return nil, typeUnknown, fmt.Errorf("%s 的右侧必须是列表，实际为%s: %s", e.op, rt, e)
+		}
+		if items, ok := e.right.(listExpr); ok && lt != typeUnknown {
+			for _, item := range items.items {
+				if it := typeOfNode(item); it != typeUnknown && it != lt {
+					return nil, typeUnknown, fmt.Errorf("%s 列表中的 %s 是%s，与左侧的%s不一致: %s", e.op, item, it, lt, e)
+				}
+			}
+		}
+		negate := e.op == "not in"
+		return func(t rete.Token, f model.Fact) (interface{}, bool) {
+			lv, lok := left(t, f)
+			rv, rok := right(t, f)
+			if !lok || !rok {
+				return nil, false
+			}
+			list := reflect.ValueOf(rv)
+			if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+				return nil, false
+			}
+			for i := 0; i < list.Len(); i++ {
+				if isTrue(compareExprValues("==", lv, list.Index(i).Interface())) {
+					return !negate, true
+				}
+			}
+			return negate, true
+		}, typeBool, nil
+	}
+	return nil, typeUnknown, fmt.Errorf("不支持的运算符: %s", e.op)
+}
+
+// typeOfNode 返回字面量节点的静态类型，其他节点返回未知类型。
+func typeOfNode(n exprNode) exprType {
+	if lit, ok := n.(literalExpr); ok {
+		return typeOfValue(lit.value)
+	}
+	return typeUnknown
+}
+
+// typeOfValue 返回运行时值对应的静态类型。
+func typeOfValue(v interface{}) exprType {
+	if v == nil {
+		return typeUnknown
+	}
+	return typeOfKind(reflect.TypeOf(v))
+}
+
+func typeOfKind(t reflect.Type) exprType {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return typeNumber
+	case reflect.String:
+		return typeString
+	case reflect.Bool:
+		return typeBool
+	case reflect.Slice, reflect.Array:
+		return typeList
+	}
+	return typeUnknown
+}
+
+// fieldType 返回事实类型 t 中字段路径 name 的静态类型，t 为 nil（未注册）时返回未知类型。
+func fieldType(t reflect.Type, name string) (exprType, error) {
+	ft, err := pathType(t, name)
+	if err != nil || ft == nil {
+		return typeUnknown, err
+	}
+	return typeOfKind(ft), nil
+}
+
+// arithmetic 计算算术运算，数值统一按 float64 计算；+ 也可用于拼接字符串。
+func arithmetic(op string, lv, rv interface{}) (interface{}, bool) {
+	l, lok := numberOf(lv)
+	r, rok := numberOf(rv)
+	if !lok || !rok {
+		ls, lok := lv.(string)
+		rs, rok := rv.(string)
+		if op == "+" && lok && rok {
+			return ls + rs, true
+		}
+		return nil, false
+	}
+
+	switch op {
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	case "/":
+		if r == 0 {
+			return nil, false
+		}
+		return l / r, true
+	case "%":
+		if r == 0 {
+			return nil, false
+		}
+		return math.Mod(l, r), true
+	}
+	return nil, false
+}
+
+// compareExprValues 按 compareValues 的归一化规则比较两个值，
+// 不可比较的值做大小比较时无法求值。
+func compareExprValues(op string, lv, rv interface{}) (interface{}, bool) {
+	switch op {
+	case "==":
+		return valuesEqual(lv, rv), true
+	case "!=":
+		return !valuesEqual(lv, rv), true
+	}
+
+	order, ok := compareOrder(lv, rv)
+	if !ok {
+		return nil, false
+	}
+	switch op {
+	case ">":
+		return order > 0, true
+	case ">=":
+		return order >= 0, true
+	case "<":
+		return order < 0, true
+	case "<=":
+		return order <= 0, true
+	}
+	return nil, false
+}
@@ -0,0 +1,89 @@
+package builder
+
+import (
+	"testing"
+
+	"code_for_article/ruleengine/agenda"
+	"code_for_article/ruleengine/model"
+	"code_for_article/ruleengine/rete"
+)
+
+func TestExprEvaluatesOnFact(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	tx := model.Transaction{ID: 1, Amount: 3000, Currency: "USD", Type: "withdraw"}
+
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{`Amount > 2 * 1000 && Currency in ["USD", "EUR"]`, true},
+		{`Amount + 500 == 3500`, true},
+		{`(Amount - 1000) / 4 >= 1000 || Type == "deposit"`, false},
+		{`!(Currency not in ['USD'])`, true},
+		{`Amount % 7 < 5 && Type + "-" + Currency == "withdraw-USD"`, true},
+		{`Amount / 0 > 1`, false},
+	}
+	for _, c := range cases {
+		tests, join, err := b.compileExpr(model.Condition{FactType: "Transaction", Expr: c.expr}, newScope())
+		if err != nil {
+			t.Fatalf("编译 %q 失败: %v", c.expr, err)
+		}
+		if join != nil {
+			t.Fatalf("%q 不引用其他事实，不应产生连接测试", c.expr)
+		}
+		got := true
+		for _, alpha := range tests {
+			got = got && alpha.test(tx)
+		}
+		if got != c.want {
+			t.Fatalf("%q 期望 %v，实际 %v", c.expr, c.want, got)
+		}
+	}
+}
+
+func TestExprSplitsJoinConjuncts(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	b.RegisterFactType("Account", model.Account{})
+	sc := newScope()
+	if err := sc.push("$acct", b.factTypes["Account"]); err != nil {
+		t.Fatalf("绑定变量失败: %v", err)
+	}
+
+	condition := model.Condition{FactType: "Transaction", Expr: `Amount > 2 * $acct.Balance && Currency in ["USD","EUR"]`}
+	tests, join, err := b.compileExpr(condition, sc)
+	if err != nil {
+		t.Fatalf("编译失败: %v", err)
+	}
+	if len(tests) != 1 || join == nil {
+		t.Fatalf("期望 1 个 Alpha 测试与 1 个连接测试，实际 %d 个 Alpha 测试，连接测试 %v", len(tests), join != nil)
+	}
+
+	tok := rete.NewToken([]model.Fact{model.Account{ID: 1, Balance: 1000}})
+	if !join(tok, model.Transaction{Amount: 2500}) {
+		t.Fatalf("2500 > 2 * 1000，期望连接成功")
+	}
+	if join(tok, model.Transaction{Amount: 1500}) {
+		t.Fatalf("1500 < 2 * 1000，期望连接失败")
+	}
+}
+
+func TestExprRejectsInvalidExpressions(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	b.RegisterFactType("Transaction", model.Transaction{})
+
+	for _, expr := range []string{
+		`Amount >`,                  // 语法错误
+		`Amount > "big"`,            // 数值与字符串比较
+		`Currency in "USD"`,         // in 右侧不是列表
+		`Currency in ["USD", 1]`,    // 列表元素类型不一致
+		`Amount + 1`,                // 结果不是布尔值
+		`Balance > 0`,               // 字段不存在
+		`Amount > $acct.Balance`,    // 未定义的绑定变量
+		`!Amount`,                   // ! 用于数值
+		`Type == "a" && Amount * 2`, // 合取项不是布尔值
+	} {
+		if _, _, err := b.compileExpr(model.Condition{FactType: "Transaction", Expr: expr}, newScope()); err == nil {
+			t.Fatalf("期望 %q 编译失败", expr)
+		}
+	}
+}
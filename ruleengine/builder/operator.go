@@ -0,0 +1,247 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// predicate 判断字段值是否满足约束，v 为 nil 表示字段值本身为 nil。
+type predicate func(v interface{}) bool
+
+// compileOperator 在加载规则时将 "字段 运算符 值" 中的运算符与值编译为 predicate。
+// fieldType 为字段的类型，已知时（事实类型已注册）会检查值与字段类型是否兼容，
+// 并将字面量转换为字段的类型（见 coerceValue）；为 nil 时只在运行时归一化比较。
+// 值的格式错误（如 between 不是两个数值、正则无法编译）、类型不兼容与未知运算符都会返回错误。
+//
+// 支持的运算符:
+//   - "==", "!=", ">", ">=", "<", "<=": 比较
+//   - "in", "not in": 字段值是否在列表 value 中
+//   - "contains": 字符串包含子串，或列表/映射包含元素/键
+//   - "matches": 字符串匹配正则表达式 value
+//   - "starts_with": 字符串以 value 开头
+//   - "between": 数值在闭区间 [value[0], value[1]] 内
+//   - "is_null", "is_not_null": 字段值是否为 nil 或零值，无需 value
+func (b *Builder) compileOperator(operator string, value interface{}, fieldType reflect.Type) (predicate, error) {
+	if fieldType != nil {
+		if err := checkOperatorField(operator, fieldType); err != nil {
+			return nil, err
+		}
+	}
+
+	switch operator {
+	case "==", "!=", ">", ">=", "<", "<=":
+		if _, isBool := value.(bool); isBool && operator != "==" && operator != "!=" {
+			return nil, fmt.Errorf("运算符 %s 不能用于布尔值", operator)
+		}
+		if fieldType != nil {
+			var err error
+			if value, err = coerceValue(fieldType, value); err != nil {
+				return nil, err
+			}
+		}
+		return func(v interface{}) bool {
+			return v != nil && b.compareValues(v, operator, value)
+		}, nil
+
+	case "in", "not in":
+		items, ok := listOf(value)
+		if !ok {
+			return nil, fmt.Errorf("%s 运算符的值必须是列表，实际为 %v", operator, value)
+		}
+		if fieldType != nil {
+			for i, item := range items {
+				coerced, err := coerceValue(fieldType, item)
+				if err != nil {
+					return nil, fmt.Errorf("%s 列表第 %d 项: %w", operator, i+1, err)
+				}
+				items[i] = coerced
+			}
+		}
+		negate := operator == "not in"
+		return func(v interface{}) bool {
+			if v == nil {
+				return false
+			}
+			for _, item := range items {
+				if b.compareValues(v, "==", item) {
+					return !negate
+				}
+			}
+			return negate
+		}, nil
+
+	case "contains":
+		element, err := containsElement(value, fieldType)
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}) bool { return b.contains(v, element) }, nil
+
+	case "matches":
+		pattern, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("matches 运算符的值必须是正则表达式字符串，实际为 %v", value)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式 %q: %w", pattern, err)
+		}
+		return func(v interface{}) bool {
+			s, ok := stringOf(v)
+			return ok && re.MatchString(s)
+		}, nil
+
+	case "starts_with":
+		prefix, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("starts_with 运算符的值必须是字符串，实际为 %v", value)
+		}
+		return func(v interface{}) bool {
+			s, ok := stringOf(v)
+			return ok && strings.HasPrefix(s, prefix)
+		}, nil
+
+	case "between":
+		items, ok := listOf(value)
+		if !ok || len(items) != 2 {
+			return nil, fmt.Errorf("between 运算符的值必须是 [下限, 上限]，实际为 %v", value)
+		}
+		low, lok := numberOf(items[0])
+		high, hok := numberOf(items[1])
+		if !lok || !hok {
+			return nil, fmt.Errorf("between 运算符的上下限必须是数值，实际为 %v", value)
+		}
+		if low > high {
+			return nil, fmt.Errorf("between 运算符的下限 %v 大于上限 %v", items[0], items[1])
+		}
+		return func(v interface{}) bool {
+			n, ok := numberOf(v)
+			return ok && n >= low && n <= high
+		}, nil
+
+	case "is_null", "is_not_null":
+		if value != nil {
+			return nil, fmt.Errorf("%s 运算符不需要值，实际为 %v", operator, value)
+		}
+		negate := operator == "is_not_null"
+		return func(v interface{}) bool { return isNull(v) != negate }, nil
+	}
+	return nil, fmt.Errorf("不支持的运算符: %q", operator)
+}
+
+// checkOperatorField 检查运算符能否用于 fieldType 类型的字段。
+func checkOperatorField(operator string, fieldType reflect.Type) error {
+	for fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	kind := fieldType.Kind()
+	var ok bool
+	switch operator {
+	case ">", ">=", "<", "<=":
+		ok = kind != reflect.Bool
+	case "contains":
+		ok = kind == reflect.String || kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map
+	case "matches", "starts_with":
+		ok = kind == reflect.String
+	case "between":
+		ok = typeOfKind(fieldType) == typeNumber
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("运算符 %s 不能用于 %s 类型的字段", operator, fieldType)
+	}
+	return nil
+}
+
+// contains 判断 container 是否包含 element：
+// 字符串判断子串，切片/数组判断元素，映射判断键。
+func (b *Builder) contains(container, element interface{}) bool {
+	if container == nil {
+		return false
+	}
+	if s, ok := stringOf(container); ok {
+		sub, ok := stringOf(element)
+		return ok && strings.Contains(s, sub)
+	}
+
+	rv := reflect.ValueOf(container)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if b.compareValues(rv.Index(i).Interface(), "==", element) {
+				return true
+			}
+		}
+	case reflect.Map:
+		for _, key := range rv.MapKeys() {
+			if b.compareValues(key.Interface(), "==", element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsElement 检查 contains 运算符的值：值必须是单个字符串、数值、布尔值或时间，
+// 字段类型已知时还需与之兼容——字符串字段要求子串为字符串，列表字段按元素类型、映射字段按键类型转换（见 coerceValue）。
+func containsElement(value interface{}, fieldType reflect.Type) (interface{}, error) {
+	if value == nil {
+		return nil, fmt.Errorf("contains 运算符需要指定值")
+	}
+	switch typeOfValue(value) {
+	case typeNumber, typeString, typeBool:
+	default:
+		if _, ok := timeOf(value); !ok {
+			return nil, fmt.Errorf("contains 运算符的值必须是单个值，实际为 %v (%T)", value, value)
+		}
+	}
+	if fieldType == nil {
+		return value, nil
+	}
+
+	fieldType = elemType(fieldType)
+	switch fieldType.Kind() {
+	case reflect.String:
+		if _, ok := stringOf(value); !ok {
+			return nil, fmt.Errorf("字符串字段的 contains 运算符的值必须是字符串，实际为 %v (%T)", value, value)
+		}
+		return value, nil
+	case reflect.Slice, reflect.Array:
+		element, err := coerceValue(fieldType.Elem(), value)
+		if err != nil {
+			return nil, fmt.Errorf("contains 列表元素: %w", err)
+		}
+		return element, nil
+	case reflect.Map:
+		key, err := coerceValue(fieldType.Key(), value)
+		if err != nil {
+			return nil, fmt.Errorf("contains 映射键: %w", err)
+		}
+		return key, nil
+	}
+	return value, nil
+}
+
+// listOf 将切片或数组（如 YAML 解析出的 []interface{}）转换为 []interface{}。
+func listOf(value interface{}) ([]interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
+
+// isNull 判断值是否为 nil 或其类型的零值（空字符串、0、nil 指针/切片/映射等）。
+func isNull(v interface{}) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
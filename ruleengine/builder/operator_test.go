@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"code_for_article/ruleengine/agenda"
+)
+
+func TestCompileOperator(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	cases := []struct {
+		operator string
+		value    interface{}
+		field    interface{}
+		want     bool
+	}{
+		{"in", []interface{}{"KP", "IR"}, "IR", true},
+		{"in", []interface{}{"KP", "IR"}, "CN", false},
+		{"not in", []interface{}{"KP", "IR"}, "CN", true},
+		{"in", []interface{}{1, 2}, 2, true},
+		{"contains", "海外", "来自海外的交易", true},
+		{"contains", "vip", []string{"new", "vip"}, true},
+		{"contains", "vip", map[string]int{"vip": 1}, true},
+		{"contains", "vip", []string{"new"}, false},
+		{"matches", `^10\.0\.\d+\.\d+$`, "10.0.3.27", true},
+		{"matches", `^10\.0\.\d+\.\d+$`, "192.168.1.1", false},
+		{"starts_with", "192.168.", "192.168.1.1", true},
+		{"starts_with", "192.168.", "10.0.0.1", false},
+		{"matches", "^go", level("gold"), true},
+		{"starts_with", "go", level("gold"), true},
+		{"contains", "ol", level("gold"), true},
+		{"contains", "gold", []level{"silver", "gold"}, true},
+		{"between", []interface{}{1000, 5000.5}, 5000.5, true},
+		{"between", []interface{}{1000, 5000}, 999, false},
+		{"is_null", nil, "", true},
+		{"is_null", nil, nil, true},
+		{"is_null", nil, (*int)(nil), true},
+		{"is_null", nil, "x", false},
+		{"is_not_null", nil, 0, false},
+		{"is_not_null", nil, 3, true},
+		{"==", "x", nil, false},
+	}
+	for _, c := range cases {
+		pred, err := b.compileOperator(c.operator, c.value, nil)
+		if err != nil {
+			t.Fatalf("%s %v 编译失败: %v", c.operator, c.value, err)
+		}
+		if got := pred(c.field); got != c.want {
+			t.Fatalf("%v %s %v 期望 %v，实际 %v", c.field, c.operator, c.value, c.want, got)
+		}
+	}
+}
+
+func TestCompileOperatorRejectsInvalidValues(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	cases := []struct {
+		operator string
+		value    interface{}
+	}{
+		{"like", "x"},
+		{"in", "KP"},
+		{"matches", "(unclosed"},
+		{"matches", 1},
+		{"starts_with", 192},
+		{"between", []interface{}{1}},
+		{"between", []interface{}{"a", "b"}},
+		{"between", []interface{}{5, 1}},
+		{"is_null", true},
+		{"contains", nil},
+		{"contains", []interface{}{"vip"}},
+	}
+	for _, c := range cases {
+		if _, err := b.compileOperator(c.operator, c.value, nil); err == nil {
+			t.Fatalf("期望 %s %v 编译失败", c.operator, c.value)
+		}
+	}
+}
+
+func TestCompileOperatorChecksContainsValue(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	invalid := []struct {
+		value interface{}
+		field reflect.Type
+	}{
+		{5, reflect.TypeOf("")},
+		{"vip", reflect.TypeOf([]int{})},
+		{true, reflect.TypeOf(map[string]int{})},
+		{"not a date", reflect.TypeOf([]time.Time{})},
+	}
+	for _, c := range invalid {
+		if _, err := b.compileOperator("contains", c.value, c.field); err == nil {
+			t.Fatalf("期望 %s 字段 contains %v 编译失败", c.field, c.value)
+		}
+	}
+
+	// 列表元素为时间时，日期字符串在加载时转换为时间
+	pred, err := b.compileOperator("contains", "2024-03-01", reflect.TypeOf([]time.Time{}))
+	if err != nil {
+		t.Fatalf("编译失败: %v", err)
+	}
+	if !pred([]time.Time{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}) {
+		t.Fatalf("期望时间列表包含 2024-03-01")
+	}
+}
@@ -0,0 +1,208 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"code_for_article/ruleengine/model"
+)
+
+// 字段路径用于在条件、连接、聚合与表达式中访问事实内部的值：
+//   - "Profile.Address.City": 嵌套结构体字段，途经的指针与接口会自动解引用；
+//   - "Payload.amount": 映射（键为字符串）中的值，适用于解码后的 JSON；
+//   - "Items[0].Price" 或 "Items.0.Price": 切片/数组下标；
+//   - "Items.len": 切片、数组、映射或字符串的长度（映射中存在 "len" 键时优先取该键）。
+//
+// 路径中途遇到 nil 指针、缺失的映射键或越界的下标时，值视为 nil（is_null 成立，其他比较不成立）；
+// 结构体没有对应字段时路径无效。
+//
+// model.AttributeFact（如 DynamicFact）的路径首段按属性名读取，属性不存在时值为 nil。
+
+var (
+	intType           = reflect.TypeOf(0)
+	attributeFactType = reflect.TypeOf((*model.AttributeFact)(nil)).Elem()
+)
+
+// pathSeg 是字段路径中的一段。
+type pathSeg struct {
+	name  string // 字段名、映射键、下标文本或 "len"
+	index int    // name 为非负整数时的下标，否则为 -1
+}
+
+// parsePath 将 "Items[0].Price" 形式的路径解析为路径段。
+func parsePath(path string) ([]pathSeg, error) {
+	normalized := strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if strings.Count(path, "[") != strings.Count(path, "]") {
+		return nil, fmt.Errorf("无效的字段路径: %s", path)
+	}
+
+	parts := strings.Split(normalized, ".")
+	segs := make([]pathSeg, len(parts))
+	for i, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("无效的字段路径: %s", path)
+		}
+		segs[i] = pathSeg{name: part, index: -1}
+		if n, err := strconv.Atoi(part); err == nil && n >= 0 {
+			segs[i].index = n
+		}
+	}
+	return segs, nil
+}
+
+// pathType 在加载规则时静态检查路径，返回路径末端的类型。
+// 途经接口、非字符串键的映射等无法静态确定的位置时返回 nil（类型未知），由运行时处理。
+func pathType(t reflect.Type, path string) (reflect.Type, error) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if t != nil && t.Implements(attributeFactType) {
+		return nil, nil // 属性在运行时才确定
+	}
+
+	for _, seg := range segs {
+		if t == nil {
+			return nil, nil
+		}
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			if t == timeType {
+				return nil, fmt.Errorf("字段路径 %s: 不能访问时间值的 %s", path, seg.name)
+			}
+			field, ok := t.FieldByName(seg.name)
+			if !ok {
+				return nil, fmt.Errorf("事实类型 %s 没有字段 %s", t.Name(), seg.name)
+			}
+			t = field.Type
+
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String || seg.name == "len" {
+				return nil, nil
+			}
+			t = t.Elem()
+
+		case reflect.Slice, reflect.Array:
+			switch {
+			case seg.name == "len":
+				t = intType
+			case seg.index >= 0:
+				t = t.Elem()
+			default:
+				return nil, fmt.Errorf("字段路径 %s: %s 是列表，只能使用下标或 len，实际为 %s", path, t, seg.name)
+			}
+
+		case reflect.String:
+			if seg.name != "len" {
+				return nil, fmt.Errorf("字段路径 %s: 字符串只能使用 len，实际为 %s", path, seg.name)
+			}
+			t = intType
+
+		case reflect.Interface:
+			return nil, nil
+
+		default:
+			return nil, fmt.Errorf("字段路径 %s: 不能在 %s 类型上访问 %s", path, t, seg.name)
+		}
+	}
+	return t, nil
+}
+
+// walkFact 在运行时沿路径读取事实的值，AttributeFact 的首段按属性读取。
+func walkFact(f model.Fact, segs []pathSeg) (interface{}, bool) {
+	if af, ok := f.(model.AttributeFact); ok {
+		v, ok := af.Attr(segs[0].name)
+		if !ok {
+			return nil, true // 缺失的属性视为 nil 值
+		}
+		return walkPath(reflect.ValueOf(v), segs[1:])
+	}
+	return walkPath(reflect.ValueOf(f), segs)
+}
+
+// walkPath 在运行时沿路径取值，ok 为 false 表示路径对该值无效（如结构体没有该字段）。
+func walkPath(v reflect.Value, segs []pathSeg) (interface{}, bool) {
+	for _, seg := range segs {
+		v = indirect(v)
+		if !v.IsValid() {
+			return nil, true // 途经 nil，视为 nil 值
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			field := v.FieldByName(seg.name)
+			if !field.IsValid() {
+				return nil, false
+			}
+			v = field
+
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			elem := v.MapIndex(reflect.ValueOf(seg.name).Convert(v.Type().Key()))
+			switch {
+			case elem.IsValid():
+				v = elem
+			case seg.name == "len":
+				v = reflect.ValueOf(v.Len())
+			default:
+				return nil, true // 缺失的键视为 nil 值
+			}
+
+		case reflect.Slice, reflect.Array, reflect.String:
+			switch {
+			case seg.name == "len":
+				v = reflect.ValueOf(v.Len())
+			case v.Kind() == reflect.String || seg.index < 0:
+				return nil, false
+			case seg.index >= v.Len():
+				return nil, true // 越界的下标视为 nil 值
+			default:
+				v = v.Index(seg.index)
+			}
+
+		default:
+			return nil, false
+		}
+	}
+
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, true
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, true
+	}
+	if !v.CanInterface() {
+		return nil, false // 未导出字段
+	}
+	return v.Interface(), true
+}
+
+// elemType 返回指针类型的元素类型，t 为 nil 时返回 nil。
+func elemType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// indirect 解开指针与接口，遇到 nil 时返回无效的 reflect.Value。
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
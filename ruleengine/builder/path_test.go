@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"code_for_article/ruleengine/agenda"
+)
+
+type address struct{ City string }
+
+type profile struct {
+	Address *address
+	Tags    []string
+}
+
+type member struct {
+	ID      string
+	Profile *profile
+	Extra   map[string]any
+}
+
+func (m member) Key() string { return m.ID }
+
+func TestFieldGetterWalksPaths(t *testing.T) {
+	b := NewBuilder(agenda.New(), nil)
+	typ := reflect.TypeOf(member{})
+	fact := member{
+		ID:      "m1",
+		Profile: &profile{Address: &address{City: "杭州"}, Tags: []string{"vip", "new"}},
+		Extra:   map[string]any{"scores": []any{3.0, 5.0}},
+	}
+	cases := []struct {
+		path   string
+		want   any
+		wantOK bool
+	}{
+		{"Profile.Address.City", "杭州", true},
+		{"Profile.Tags.len", 2, true},
+		{"Profile.Tags[1]", "new", true},
+		{"Profile.Tags.0", "vip", true},
+		{"Profile.Tags[5]", nil, true},
+		{"Extra.scores[1]", 5.0, true},
+		{"Extra.missing", nil, true},
+		{"Profile.Nickname", nil, false},
+	}
+	for _, c := range cases {
+		got, ok := b.fieldGetter(typ, c.path)(fact)
+		if ok != c.wantOK || got != c.want {
+			t.Fatalf("%s 期望 (%v, %v)，实际 (%v, %v)", c.path, c.want, c.wantOK, got, ok)
+		}
+	}
+
+	// 途经 nil 指针时视为 nil 值
+	if got, ok := b.fieldGetter(typ, "Profile.Address.City")(member{ID: "m2"}); !ok || got != nil {
+		t.Fatalf("期望 (nil, true)，实际 (%v, %v)", got, ok)
+	}
+
+	for _, path := range []string{"Profile.Nickname", "Profile.Tags.first", "Profile.Address.City.Name", "Profile..City"} {
+		if _, err := pathType(typ, path); err == nil {
+			t.Fatalf("期望路径 %s 校验失败", path)
+		}
+	}
+	if ft, err := pathType(typ, "Extra.scores[0]"); err != nil || ft != nil {
+		t.Fatalf("期望映射中的值类型未知，实际 (%v, %v)", ft, err)
+	}
+}
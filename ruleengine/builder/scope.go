@@ -0,0 +1,100 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"code_for_article/ruleengine/model"
+)
+
+// factRef 指向 Token 中的某个事实，field 为空时表示事实本身。
+type factRef struct {
+	index int
+	field string
+}
+
+// scope 记录编译一条规则时，已编译条件在 Token 中的位置及绑定变量。
+//
+// fact 条件（以及作为首个条件的 aggregate）会向 Token 追加一个事实，
+// not / exists 不追加事实，因此绑定变量必须在编译时解析为 Token 下标。
+type scope struct {
+	bindings map[string]int // 变量名（不含 "$"）-> Token 下标
+	types    []reflect.Type // Token 下标 -> 事实类型，未注册的类型为 nil
+	size     int            // 当前 Token 中的事实数量
+}
+
+func newScope() *scope {
+	return &scope{bindings: make(map[string]int)}
+}
+
+// push 记录一个向 Token 追加 typ 类型事实的条件，bind 非空时为其绑定变量。
+func (s *scope) push(bind string, typ reflect.Type) error {
+	s.size++
+	s.types = append(s.types, typ)
+	if bind == "" {
+		return nil
+	}
+	name := strings.TrimPrefix(bind, "$")
+	if _, err := strconv.Atoi(name); err == nil || name == "" {
+		return fmt.Errorf("无效的绑定变量名: %s", bind)
+	}
+	if _, ok := s.bindings[name]; ok {
+		return fmt.Errorf("重复的绑定变量: %s", bind)
+	}
+	s.bindings[name] = s.size - 1
+	return nil
+}
+
+// parseRef 解析事实引用：
+//   - "$p" / "$p.UserID" / "$p.Profile.City": 绑定变量 p 对应的事实（及其字段路径）；
+//   - "$0" / "$0.UserID": Token 中第 1 个事实（及其字段）。
+func (s *scope) parseRef(ref string) (factRef, error) {
+	if !strings.HasPrefix(ref, "$") {
+		return factRef{}, fmt.Errorf("无效的事实引用: %s", ref)
+	}
+	name, field, _ := strings.Cut(strings.TrimPrefix(ref, "$"), ".")
+
+	index, err := strconv.Atoi(name)
+	if err != nil {
+		var ok bool
+		if index, ok = s.bindings[name]; !ok {
+			return factRef{}, fmt.Errorf("未定义的绑定变量: $%s", name)
+		}
+	}
+	if index < 0 || index >= s.size {
+		return factRef{}, fmt.Errorf("事实引用 %s 超出范围，规则匹配的 Token 仅有 %d 个事实", ref, s.size)
+	}
+	return factRef{index: index, field: field}, nil
+}
+
+// parseField 解析连接条件中左侧字段：以 "$" 开头时按事实引用解析，
+// 否则沿用旧语义，指向 Token 中最后一个事实的字段。
+func (s *scope) parseField(field string) (factRef, error) {
+	if strings.HasPrefix(field, "$") {
+		ref, err := s.parseRef(field)
+		if err != nil {
+			return factRef{}, err
+		}
+		if ref.field == "" {
+			return factRef{}, fmt.Errorf("连接字段必须指定字段名: %s", field)
+		}
+		return ref, nil
+	}
+	if s.size == 0 {
+		return factRef{}, fmt.Errorf("连接字段 %s 没有可连接的事实", field)
+	}
+	return factRef{index: s.size - 1, field: field}, nil
+}
+
+// isFactRef 判断 Data 中的值是否是事实引用。
+func isFactRef(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, "$")
+}
+
+// extendsToken 判断条件是否向 Token 追加事实。
+func extendsToken(condition model.Condition) bool {
+	return condition.Type == "fact" || condition.Type == "aggregate"
+}
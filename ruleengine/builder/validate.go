@@ -0,0 +1,226 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"code_for_article/ruleengine/model"
+)
+
+// Problem 描述规则校验发现的一个问题。
+type Problem struct {
+	Rule      string // 规则名
+	Branch    int    // OR 展开后的分支序号（从 1 开始），规则没有 OR 时为 0
+	Condition int    // 条件序号（从 1 开始），0 表示问题不在条件中（如动作）
+	Err       error
+}
+
+func (p Problem) Error() string {
+	var where []string
+	if p.Branch > 0 {
+		where = append(where, fmt.Sprintf("第 %d 个 OR 分支", p.Branch))
+	}
+	if p.Condition > 0 {
+		where = append(where, fmt.Sprintf("第 %d 个条件", p.Condition))
+	}
+	if len(where) == 0 {
+		return fmt.Sprintf("规则 '%s': %v", p.Rule, p.Err)
+	}
+	return fmt.Sprintf("规则 '%s' %s: %v", p.Rule, strings.Join(where, " "), p.Err)
+}
+
+func (p Problem) Unwrap() error { return p.Err }
+
+// ValidationError 汇总一批规则的全部校验问题。
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("规则校验失败，共 %d 个问题:", len(e.Problems)))
+	for _, p := range e.Problems {
+		lines = append(lines, "  - "+p.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ValidateRules 在构建网络之前校验规则，返回 *ValidationError 汇总全部问题，没有问题时返回 nil。
+//
+// 校验内容包括：事实类型是否已注册、字段是否存在、运算符与值的类型是否与字段兼容、
+// 表达式能否通过类型检查、绑定变量与动作引用能否解析。
+// 校验只编译条件的测试函数，不创建任何节点。
+func (b *Builder) ValidateRules(rules []model.Rule) error {
+	var problems []Problem
+	for _, rule := range rules {
+		problems = append(problems, b.validateRule(rule)...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+func (b *Builder) validateRule(rule model.Rule) []Problem {
+	var (
+		problems []Problem
+		seen     = make(map[string]bool) // OR 分支共享的前缀只报告一次
+	)
+	report := func(branch, condition int, err error) {
+		p := Problem{Rule: rule.Name, Branch: branch, Condition: condition, Err: err}
+		key := fmt.Sprintf("%d|%v", condition, err)
+		if !seen[key] {
+			seen[key] = true
+			problems = append(problems, p)
+		}
+	}
+
+	if len(rule.When) == 0 {
+		report(0, 0, fmt.Errorf("没有条件"))
+		return problems
+	}
+	branches, err := expandOr(rule.When)
+	if err != nil {
+		report(0, 0, err)
+		return problems
+	}
+
+	var scopes []*scope
+	for n, branch := range branches {
+		branchNo := 0
+		if len(branches) > 1 {
+			branchNo = n + 1
+		}
+
+		sc := newScope()
+		for i, condition := range branch {
+			for _, err := range b.validateCondition(i, condition, sc) {
+				report(branchNo, i+1, err)
+			}
+			if extendsToken(condition) {
+				if err := sc.push(condition.Bind, b.tokenFactType(condition)); err != nil {
+					report(branchNo, i+1, err)
+				}
+			}
+		}
+		scopes = append(scopes, sc)
+	}
+
+	if err := checkActionRefs(rule.Then, scopes); err != nil {
+		report(0, 0, err)
+	}
+	if _, err := b.createAction(rule.Name, rule.Then, scopes[0]); err != nil {
+		report(0, 0, err)
+	} else if err := b.validateActionData(rule.Then, scopes[0]); err != nil {
+		report(0, 0, err)
+	}
+	return problems
+}
+
+// validateCondition 校验第 i 个条件，sc 为此前条件的编译结果。
+func (b *Builder) validateCondition(i int, condition model.Condition, sc *scope) []error {
+	var errs []error
+
+	switch condition.Type {
+	case "fact", "not", "exists":
+	case "aggregate":
+		// 非首个条件的聚合按连接关系对每个左侧 Token 聚合，分组即左侧 Token
+		if i > 0 && condition.GroupBy != "" {
+			errs = append(errs, fmt.Errorf("非首个条件的 aggregate 按 join 与左侧事实关联，不支持 group_by"))
+		}
+	default:
+		return append(errs, fmt.Errorf("不支持的条件类型: %s", condition.Type))
+	}
+
+	factType, ok := b.factTypes[condition.FactType]
+	switch {
+	case condition.FactType == "":
+		return append(errs, fmt.Errorf("缺少 fact_type"))
+	case condition.FactType != "" && !ok:
+		return append(errs, fmt.Errorf("未注册的事实类型 '%s'", condition.FactType))
+	}
+
+	if condition.Type == "aggregate" {
+		if condition.AggregateField != "" {
+			if err := checkField(factType, condition.AggregateField); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if _, err := b.compileAggregate(condition); err != nil {
+			errs = append(errs, err)
+		}
+		if i == 0 {
+			if _, err := b.compileGroupBy(condition); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if condition.Window != nil {
+			if _, err := b.compileWindow(condition); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	} else if condition.Window != nil {
+		errs = append(errs, fmt.Errorf("window 只能用于 aggregate 条件"))
+	}
+
+	// 逐项编译字段约束，报告全部有问题的约束
+	for _, constraint := range constraintsOf(condition) {
+		if _, err := b.compileConstraint(constraint, factType); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if condition.Expr != "" {
+		if _, _, err := b.compileExpr(condition, sc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if condition.Join != nil {
+		left, err := sc.parseField(condition.Join.LeftField)
+		if err == nil {
+			err = checkField(sc.types[left.index], left.field)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("连接字段: %w", err))
+		}
+		if err := checkField(factType, condition.Join.RightField); err != nil {
+			errs = append(errs, fmt.Errorf("连接字段: %w", err))
+		}
+	}
+
+	if !extendsToken(condition) && condition.Bind != "" {
+		errs = append(errs, fmt.Errorf("%s 条件不产生事实，不能绑定变量 %s", condition.Type, condition.Bind))
+	}
+	return errs
+}
+
+// validateActionData 校验 assert/modify 动作 Data 中的字段是否存在于目标事实类型。
+func (b *Builder) validateActionData(action model.Action, sc *scope) error {
+	var target reflect.Type
+	switch action.Type {
+	case "assert", "assert_logical":
+		target = b.factTypes[action.FactType]
+	case "modify":
+		ref, err := b.parseTarget(action, sc)
+		if err != nil {
+			return err
+		}
+		target = sc.types[ref.index]
+	default:
+		return nil
+	}
+
+	for field := range action.Data {
+		if err := checkField(target, field); err != nil {
+			return fmt.Errorf("%s 动作: %w", action.Type, err)
+		}
+	}
+	return nil
+}
+
+// checkField 检查事实类型 t 是否有字段 name，t 为 nil（类型未知）时不检查。
+func checkField(t reflect.Type, name string) error {
+	_, err := fieldType(t, name)
+	return err
+}
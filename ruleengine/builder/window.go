@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"code_for_article/ruleengine/model"
+	"code_for_article/ruleengine/rete"
+)
+
+// SetClock 设置时间窗口使用的时钟，默认为系统时钟。
+func (b *Builder) SetClock(c rete.Clock) {
+	b.clock = c
+}
+
+// Windows 返回已构建的全部 WindowNode，引擎推进时钟后据此撤回过期事实。
+func (b *Builder) Windows() []*rete.WindowNode {
+	return b.windows
+}
+
+// windowSource 返回聚合条件右输入的来源：没有窗口时为条件的 AlphaNode，
+// 否则在 AlphaNode 之后接入 WindowNode，只让窗口内的事实进入聚合。
+func (b *Builder) windowSource(condition model.Condition, alphaNode *rete.AlphaNode) (rete.Node, error) {
+	if condition.Window == nil {
+		return alphaNode, nil
+	}
+	spec, err := b.compileWindow(condition)
+	if err != nil {
+		return nil, err
+	}
+	spec.Clock = b.clock
+	window := rete.NewWindowNode(spec)
+	alphaNode.AddChild(window)
+	b.windows = append(b.windows, window)
+	return window, nil
+}
+
+// compileWindow 将条件的 window 编译为 WindowSpec（不含时钟）。
+func (b *Builder) compileWindow(condition model.Condition) (rete.WindowSpec, error) {
+	w := condition.Window
+	var spec rete.WindowSpec
+	switch w.Type {
+	case "", "sliding":
+	case "tumbling":
+		spec.Tumbling = true
+	default:
+		return spec, fmt.Errorf("不支持的窗口类型: %s", w.Type)
+	}
+
+	switch {
+	case w.Length != 0 && w.Duration != "":
+		return spec, fmt.Errorf("窗口的 length 与 duration 只能指定一个")
+	case w.Length < 0:
+		return spec, fmt.Errorf("窗口的 length 必须大于 0，实际为 %d", w.Length)
+	case w.Length > 0:
+		if w.TimestampField != "" {
+			return spec, fmt.Errorf("timestamp_field 只能用于按时间的窗口")
+		}
+		spec.Length = w.Length
+		return spec, nil
+	case w.Duration == "":
+		return spec, fmt.Errorf("窗口需要指定 length 或 duration")
+	}
+
+	d, err := time.ParseDuration(w.Duration)
+	if err != nil {
+		return spec, fmt.Errorf("无效的窗口时长 %q: %w", w.Duration, err)
+	}
+	if d <= 0 {
+		return spec, fmt.Errorf("窗口的 duration 必须大于 0，实际为 %s", w.Duration)
+	}
+	spec.Duration = d
+
+	if w.TimestampField != "" {
+		factType := b.factTypes[condition.FactType]
+		fieldType, err := pathType(factType, w.TimestampField)
+		if err != nil {
+			return spec, fmt.Errorf("timestamp_field: %w", err)
+		}
+		if fieldType != nil && !isTimestampType(elemType(fieldType)) {
+			return spec, fmt.Errorf("timestamp_field 字段 %s 的类型 %s 不能作为时间", w.TimestampField, fieldType)
+		}
+		getTime := b.fieldGetter(factType, w.TimestampField)
+		spec.Timestamp = func(f model.Fact) (time.Time, bool) {
+			v, _ := getTime(f)
+			return timestampOf(v)
+		}
+	}
+	return spec, nil
+}
+
+// isTimestampType 判断类型能否作为事件时间：time.Time、数值（Unix 秒数）或字符串。
+func isTimestampType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Interface:
+		return true
+	}
+	return t == timeType
+}
+
+// timestampOf 将字段值转换为时间：time.Time 原样返回，数值视为 Unix 秒数，字符串按 timeLayouts 解析。
+func timestampOf(v interface{}) (time.Time, bool) {
+	if t, ok := timeOf(v); ok {
+		return t, true
+	}
+	if sec, ok := integerOf(v); ok {
+		return time.Unix(sec, 0), true
+	}
+	if sec, ok := numberOf(v); ok {
+		whole, frac := math.Modf(sec)
+		return time.Unix(int64(whole), int64(frac*1e9)), true
+	}
+	return parseTimeValue(v)
+}
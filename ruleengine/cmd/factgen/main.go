@@ -0,0 +1,191 @@
+// factgen 为实现了 model.Fact 的结构体生成字段读取表，替代规则求值时的反射。
+//
+// 在事实类型所在的包中添加:
+//
+//	//go:generate go run code_for_article/ruleengine/cmd/factgen
+//
+// 运行 go generate 后生成 fact_accessors_gen.go，其中的 init 函数通过
+// model.RegisterAccessors 注册包内每个实现了 Key() string 的结构体的导出字段。
+// Builder 编译条件时会自动使用这些读取函数，未生成的类型回退到反射。
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const modelImport = "code_for_article/ruleengine/model"
+
+// factType 是一个需要生成读取表的事实类型。
+type factType struct {
+	Name       string
+	PointerKey bool // Key 方法的接收者是指针，只有 *T 实现了 Fact
+	Fields     []string
+}
+
+func main() {
+	dir := flag.String("dir", ".", "事实类型所在的包目录")
+	output := flag.String("output", "fact_accessors_gen.go", "生成的文件名（相对于 -dir）")
+	flag.Parse()
+
+	pkg, types, err := scan(*dir, *output)
+	if err != nil {
+		log.Fatalf("factgen: %v", err)
+	}
+	src, err := generate(pkg, types)
+	if err != nil {
+		log.Fatalf("factgen: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0o644); err != nil {
+		log.Fatalf("factgen: %v", err)
+	}
+}
+
+// scan 解析 dir 中的 Go 源文件（跳过测试文件与 output 本身），
+// 返回包名及其中实现了 Key() string 的结构体。
+func scan(dir, output string) (string, []factType, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var (
+		pkg     string
+		fset    = token.NewFileSet()
+		structs = make(map[string]*ast.StructType)
+		keys    = make(map[string]bool) // 类型名 -> Key 方法的接收者是否为指针
+	)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") || filepath.Base(file) == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return "", nil, err
+		}
+		pkg = f.Name.Name
+
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok || ts.TypeParams != nil {
+						continue
+					}
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						structs[ts.Name.Name] = st
+					}
+				}
+			case *ast.FuncDecl:
+				if name, pointer, ok := keyMethod(d); ok {
+					keys[name] = pointer
+				}
+			}
+		}
+	}
+	if pkg == "" {
+		return "", nil, fmt.Errorf("%s 中没有 Go 源文件", dir)
+	}
+
+	var types []factType
+	for name, pointer := range keys {
+		st, ok := structs[name]
+		if !ok {
+			continue
+		}
+		t := factType{Name: name, PointerKey: pointer}
+		for _, field := range st.Fields.List {
+			for _, ident := range field.Names { // 跳过嵌入字段
+				if ident.IsExported() {
+					t.Fields = append(t.Fields, ident.Name)
+				}
+			}
+		}
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return pkg, types, nil
+}
+
+// keyMethod 判断 d 是否为 func (T) Key() string，返回接收者类型名及其是否为指针。
+func keyMethod(d *ast.FuncDecl) (name string, pointer bool, ok bool) {
+	if d.Recv == nil || len(d.Recv.List) != 1 || d.Name.Name != "Key" {
+		return "", false, false
+	}
+	if len(d.Type.Params.List) != 0 || d.Type.Results == nil || len(d.Type.Results.List) != 1 {
+		return "", false, false
+	}
+	if result, ok := d.Type.Results.List[0].Type.(*ast.Ident); !ok || result.Name != "string" {
+		return "", false, false
+	}
+
+	recv := d.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv, pointer = star.X, true
+	}
+	ident, ok := recv.(*ast.Ident)
+	if !ok {
+		return "", false, false
+	}
+	return ident.Name, pointer, true
+}
+
+var tmpl = template.Must(template.New("accessors").Parse(`// Code generated by factgen; DO NOT EDIT.
+
+package {{.Package}}
+{{if .Qualifier}}
+import "` + modelImport + `"
+{{end}}
+func init() {
+{{- range .Types}}
+{{- $type := .}}
+	{{$.Qualifier}}RegisterAccessors({{if .PointerKey}}&{{end}}{{.Name}}{}, map[string]{{$.Qualifier}}FieldGetter{
+	{{- range .Fields}}
+		"{{.}}": func(f {{$.Qualifier}}Fact) (any, bool) {
+			switch v := f.(type) {
+			{{- if not $type.PointerKey}}
+			case {{$type.Name}}:
+				return v.{{.}}, true
+			{{- end}}
+			case *{{$type.Name}}:
+				return v.{{.}}, true
+			}
+			return nil, false
+		},
+	{{- end}}
+	})
+{{- end}}
+}
+`))
+
+// generate 渲染并格式化生成的代码。
+// model 包自身生成时不需要限定包名，其他包通过导入 model 注册。
+func generate(pkg string, types []factType) ([]byte, error) {
+	qualifier := "model."
+	if pkg == "model" {
+		qualifier = ""
+	}
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, struct {
+		Package   string
+		Qualifier string
+		Types     []factType
+	}{pkg, qualifier, types})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestModelAccessorsUpToDate 确保 model 包中提交的生成代码与事实类型定义一致。
+func TestModelAccessorsUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..", "model")
+	pkg, types, err := scan(dir, "fact_accessors_gen.go")
+	if err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	want, err := generate(pkg, types)
+	if err != nil {
+		t.Fatalf("生成失败: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "fact_accessors_gen.go"))
+	if err != nil {
+		t.Fatalf("读取生成文件失败: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("model/fact_accessors_gen.go 已过期，请运行 go generate ./model")
+	}
+}
+
+func TestGenerateQualifiesModelOutsideModelPackage(t *testing.T) {
+	src, err := generate("orders", []factType{{Name: "Order", PointerKey: true, Fields: []string{"ID"}}})
+	if err != nil {
+		t.Fatalf("生成失败: %v", err)
+	}
+	for _, want := range []string{`import "code_for_article/ruleengine/model"`, "model.RegisterAccessors(&Order{}", "case *Order:"} {
+		if !bytes.Contains(src, []byte(want)) {
+			t.Fatalf("生成代码缺少 %q:\n%s", want, src)
+		}
+	}
+	if bytes.Contains(src, []byte("case Order:")) {
+		t.Fatalf("Key 为指针接收者时值类型未实现 Fact，不应生成 case Order:\n%s", src)
+	}
+}
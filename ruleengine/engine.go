@@ -2,7 +2,11 @@ package ruleengine
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"reflect"
+	"slices"
+	"time"
 
 	"code_for_article/ruleengine/agenda"
 	"code_for_article/ruleengine/builder"
@@ -13,23 +17,92 @@ import (
 
 // Engine 汇聚 rete 网络、agenda 与工作内存。
 type Engine struct {
-	alphaRoots []*rete.AlphaNode
-	ag         *agenda.Agenda
-	builder    *builder.Builder
+	root    *rete.RootNode // 按事实类型分派到顶层 AlphaNode
+	ag      *agenda.Agenda
+	builder *builder.Builder
+	facts   map[string]model.Fact // 工作内存：Key -> 当前版本的事实
+	clock   rete.Clock            // 驱动时间窗口，见 SetClock
+
+	// 真值维护，见 truth.go
+	justified       map[string]map[string]bool // 逻辑事实 Key -> 依据集合
+	supports        map[string][]string        // 依据 -> 其支撑的逻辑事实 Key
+	removing        map[string]bool            // 传播期间等待移除的依据
+	pendingRemovals []string                   // removing 中的依据，按移除顺序
+	unsupported     []model.Fact               // 传播期间失去全部依据、等待撤回的事实
+	propagating     bool
 }
 
 // New 创建一个新的规则引擎实例。
 func New() *Engine {
 	ag := agenda.New()
-	return &Engine{
-		ag:      ag,
-		builder: builder.NewBuilder(ag),
+	e := &Engine{
+		root:      rete.NewRootNode(),
+		ag:        ag,
+		facts:     make(map[string]model.Fact),
+		clock:     rete.SystemClock(),
+		justified: make(map[string]map[string]bool),
+		supports:  make(map[string][]string),
+		removing:  make(map[string]bool),
+	}
+	e.builder = builder.NewBuilder(ag, e)
+	// 窗口经由引擎读取时间，SetClock 对已加载的规则同样生效
+	e.builder.SetClock(rete.ClockFunc(func() time.Time { return e.clock.Now() }))
+	for _, prototype := range builtinFactTypes {
+		e.RegisterFactType(model.TypeNameOf(prototype), prototype)
+		e.RegisterFactType(model.QualifiedTypeNameOf(prototype), prototype)
 	}
+	// 初始事实节点只接收引擎自己的初始事实，同名的业务类型不会进入
+	e.root.BindType(rete.InitialFactType, reflect.TypeOf(rete.InitialFact{}))
+	return e
+}
+
+// builtinFactTypes 是 model 包中的业务实体，New 时以类型名（如 "User"）
+// 与带包路径的类型名（如 "code_for_article/ruleengine/model.User"）注册。
+var builtinFactTypes = []model.Fact{
+	model.User{},
+	model.Account{},
+	model.Transaction{},
+	model.LoginAttempt{},
+	model.SecurityAlert{},
+	model.Cart{},
+	model.UserProfile{},
+	model.FailedAttempt{},
+	model.DeviceInfo{},
+	model.GenericFact{}, // Payload 通过字段路径访问，如 Payload.amount
+}
+
+// RegisterFactType 以 name 在事实类型注册表中注册 Go 类型。
+//
+// 规则条件中的 fact_type 必须是已注册的类型名，LoadRules 据此校验字段是否存在、
+// 运算符与值的类型是否兼容；assert 动作也按 fact_type 从注册表创建事实。
+// model 包中的业务实体已默认注册，自定义事实类型需在加载规则前注册：
+//
+//	engine.RegisterFactType("Order", Order{})
+//
+// prototype 可以是指针（&Order{}），此时 assert 动作插入指针事实；无论以哪种方式注册，
+// 值事实与指针事实都能匹配。注册后 name 绑定到该 Go 类型：其他包中的同名类型不会匹配 name，
+// 不同包的同名类型可分别以带包路径的名称注册（见 model.QualifiedTypeNameOf）。
+func (e *Engine) RegisterFactType(name string, prototype model.Fact) {
+	e.builder.RegisterFactType(name, prototype)
+	if _, dynamic := prototype.(model.TypeNamer); !dynamic {
+		e.root.BindType(name, reflect.TypeOf(prototype))
+	}
+}
+
+// RegisterDynamicType 注册动态事实类型 name，规则中 fact_type 为 name 的条件匹配
+// Type 为 name 的 model.DynamicFact。动态类型的属性在运行时才确定，加载规则时不检查字段：
+//
+//	engine.RegisterDynamicType("Order")
+//	facts, err := model.DecodeJSON(payload)
+func (e *Engine) RegisterDynamicType(name string) {
+	e.RegisterFactType(name, model.DynamicFact{Type: name})
 }
 
 // AddAlphaRoot 将顶层 AlphaNode 注册给引擎。
+// 通过 rete.NewTypedAlphaNode 声明了类型的节点只会收到该类型的事实，
+// 未声明类型的节点接收所有事实。
 func (e *Engine) AddAlphaRoot(nodes ...*rete.AlphaNode) {
-	e.alphaRoots = append(e.alphaRoots, nodes...)
+	e.root.Add(nodes...)
 }
 
 // LoadRulesFromYAML 从 YAML 文件加载规则并构建 Rete 网络。
@@ -48,32 +121,167 @@ func (e *Engine) LoadRulesFromYAML(filename string) error {
 }
 
 // LoadRules 加载规则列表并构建 Rete 网络。
+// 可以多次调用；工作内存中已有的事实会与新加载的规则匹配。
 func (e *Engine) LoadRules(rules []model.Rule) error {
+	// 先校验全部规则，一次性报告所有问题（*builder.ValidationError）
+	if err := e.builder.ValidateRules(rules); err != nil {
+		return err
+	}
+	// 工作内存非空时，新规则使用独立的节点，构建完成后重放已有事实，
+	// 使之后加载的规则与先加载的规则看到相同的工作内存
+	replay := len(e.facts) > 0
+	if replay {
+		e.builder.ResetSharing()
+	}
+
+	var initial []*rete.AlphaNode
 	for _, rule := range rules {
 		roots, err := e.builder.BuildRule(rule)
 		if err != nil {
 			return fmt.Errorf("构建规则 '%s' 失败: %w", rule.Name, err)
 		}
 		e.AddAlphaRoot(roots...)
+		for _, root := range roots {
+			if root.FactType() == rete.InitialFactType {
+				initial = append(initial, root)
+			}
+		}
+	}
+	if replay {
+		e.replayFacts()
 	}
+	e.assertInitialFact(initial)
 	return nil
 }
 
-// AddFact 插入新事实。
+// replayFacts 按 Key 顺序将工作内存中的事实重新送入网络。
+// 已有节点的 AlphaMemory 中已存在这些事实，不会重复传播；只有新建的节点会收到。
+func (e *Engine) replayFacts() {
+	for _, key := range slices.Sorted(maps.Keys(e.facts)) {
+		e.assert(e.facts[key])
+	}
+}
+
+// assertInitialFact 向以 not / exists 开头的规则插入初始事实，使其在没有任何事实时也能匹配。
+//
+// 初始事实由引擎管理：每条此类规则拥有独立的初始事实节点，规则加载后立即向新节点插入，
+// 之后一直保留；它不进入工作内存，GetFact、RetractFact 都看不到它。
+func (e *Engine) assertInitialFact(nodes []*rete.AlphaNode) {
+	for _, n := range nodes {
+		e.propagate(func() { n.AssertFact(rete.InitialFact{}) })
+	}
+}
+
+// SetClock 设置驱动时间窗口的时钟，默认为系统时钟。
+// 测试或回放历史事件时使用 rete.PseudoClock，窗口的行为完全由调用方推进的时间决定：
+//
+//	clock := rete.NewPseudoClock(start)
+//	engine.SetClock(clock)
+//	clock.Advance(10 * time.Minute)
+//	engine.Tick()
+func (e *Engine) SetClock(c rete.Clock) {
+	e.clock = c
+}
+
+// Tick 按时钟的当前时间撤回已离开时间窗口的事实，聚合结果随之反向扣除。
+// 插入事实时窗口会顺带清理过期事实；没有新事实时需在推进时钟后（或定期）调用 Tick。
+func (e *Engine) Tick() {
+	for _, w := range e.builder.Windows() {
+		e.propagate(w.Expire)
+	}
+}
+
+// AddFact 插入新事实。Key 已存在时忽略，修改已有事实请使用 UpdateFact。
 func (e *Engine) AddFact(f model.Fact) {
-	for _, n := range e.alphaRoots {
-		n.AssertFact(f)
+	if _, ok := e.facts[f.Key()]; ok {
+		return
 	}
+	e.facts[f.Key()] = f
+	e.assert(f)
 }
 
 // RetractFact 撤回事实。
+// 按 Key 找到工作内存中的当前版本再撤回，因此传入的可以是旧版本或只带主键的事实。
 func (e *Engine) RetractFact(f model.Fact) {
-	for _, n := range e.alphaRoots {
-		n.RetractFact(f)
+	stored, ok := e.facts[f.Key()]
+	if !ok {
+		return
+	}
+	delete(e.facts, f.Key())
+	e.forgetJustifications(f.Key())
+	e.retract(stored)
+}
+
+// UpdateFact 用 f 替换工作内存中 Key 相同的事实，Key 不存在时等同于 AddFact。
+// f 与当前版本完全相同（reflect.DeepEqual）时什么也不做。
+//
+// 实现上先按旧版本撤回，再断言新版本：
+//   - 旧版本参与的 Token（Alpha/Beta/Not/Exists 各节点内存与 Agenda 中的激活项）全部撤回；
+//   - 新版本重新匹配，产生新的 Token 与激活项。
+//
+// 因此即使修改的字段与条件无关，仍然满足条件的规则也会以新版本的事实重新激活、再次触发；
+// 只有规则自己的 modify 动作引起的重新匹配不会激活（见 rete.TerminalNode）。
+func (e *Engine) UpdateFact(f model.Fact) {
+	old, ok := e.facts[f.Key()]
+	if !ok {
+		e.AddFact(f)
+		return
+	}
+	if unchanged(old, f) {
+		return
+	}
+	e.facts[f.Key()] = f
+	// 撤回与断言在同一次传播中完成，仍然匹配的 Token 保留其支撑的逻辑事实
+	e.propagate(func() {
+		e.root.RetractFact(old)
+		e.root.AssertFact(f)
+	})
+}
+
+// unchanged 判断 f 与工作内存中的 old 是否相同。
+// 同一个指针可能已被原地修改，无法与插入时的值比较，因此不视为相同。
+func unchanged(old, f model.Fact) bool {
+	ov, fv := reflect.ValueOf(old), reflect.ValueOf(f)
+	if ov.Kind() == reflect.Ptr && fv.Kind() == reflect.Ptr && ov.Pointer() == fv.Pointer() {
+		return false
 	}
+	return reflect.DeepEqual(old, f)
+}
+
+// GetFact 按 Key 查询工作内存中的事实。
+func (e *Engine) GetFact(key string) (model.Fact, bool) {
+	f, ok := e.facts[key]
+	return f, ok
+}
+
+// assert 将事实送入网络。
+func (e *Engine) assert(f model.Fact) {
+	e.propagate(func() { e.root.AssertFact(f) })
+}
+
+// retract 将事实的撤回信号送入网络。
+func (e *Engine) retract(f model.Fact) {
+	e.propagate(func() { e.root.RetractFact(f) })
+}
+
+// propagate 执行一次断言或撤回传播。
+// 传播期间失去依据的逻辑事实在传播结束后统一撤回，避免嵌套修改网络。
+func (e *Engine) propagate(fn func()) {
+	if e.propagating {
+		fn()
+		return
+	}
+
+	e.propagating = true
+	fn()
+	e.propagating = false
+	e.flushJustifications()
+	e.retractUnsupported()
 }
 
 // FireAllRules 持续触发 agenda 直到为空。
+// 动作中插入、撤回或修改的事实会立即在网络中传播并产生新的激活项，
+// 因此该循环会一直执行"匹配-冲突解决-执行"直到工作内存不再产生新的激活。
 func (e *Engine) FireAllRules() {
 	for {
 		act, ok := e.ag.Next()
@@ -0,0 +1,932 @@
+package ruleengine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"code_for_article/ruleengine/builder"
+	"code_for_article/ruleengine/model"
+	"code_for_article/ruleengine/rete"
+)
+
+var lockedUserRule = model.Rule{
+	Name: "锁定用户检测",
+	When: []model.Condition{
+		{Type: "fact", FactType: "User", Field: "Status", Operator: "==", Value: "locked"},
+	},
+	Then: model.Action{Type: "log", Message: "检测到锁定用户"},
+}
+
+func TestRetractCancelsPendingActivation(t *testing.T) {
+	e := New()
+	if err := e.LoadRules([]model.Rule{lockedUserRule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	user := model.User{ID: 1, Status: "locked"}
+	e.AddFact(user)
+	if got := e.Agenda().Size(); got != 1 {
+		t.Fatalf("期望 1 个激活项，实际 %d", got)
+	}
+
+	e.RetractFact(user)
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("撤回后期望 0 个激活项，实际 %d", got)
+	}
+}
+
+func TestAssertActionChainsRules(t *testing.T) {
+	e := New()
+	e.RegisterFactType("SecurityAlert", model.SecurityAlert{})
+	rules := []model.Rule{
+		{
+			Name: "大额交易生成警报",
+			When: []model.Condition{
+				{Type: "fact", FactType: "Transaction", Field: "Amount", Operator: ">", Value: 50000},
+			},
+			Then: model.Action{
+				Type:     "assert",
+				FactType: "SecurityAlert",
+				Data: map[string]interface{}{
+					"ID":     "$0.ID",
+					"UserID": "$0.UserID",
+					"Type":   "fraud_risk",
+					"Level":  "high",
+				},
+			},
+		},
+		{
+			Name: "高危警报处理",
+			When: []model.Condition{
+				{Type: "fact", FactType: "SecurityAlert", Field: "Level", Operator: "==", Value: "high"},
+			},
+			Then: model.Action{Type: "retract", Target: "$0"},
+		},
+	}
+	if err := e.LoadRules(rules); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	e.AddFact(model.Transaction{ID: 9, UserID: 3, Amount: 60000})
+	act, ok := e.Agenda().Next()
+	if !ok || act.RuleName != "大额交易生成警报" {
+		t.Fatalf("期望先激活大额交易规则，实际 %+v", act)
+	}
+	act.Action()
+
+	act, ok = e.Agenda().Next()
+	if !ok || act.RuleName != "高危警报处理" {
+		t.Fatalf("assert 的事实应激活后续规则，实际 %+v", act)
+	}
+	alert := act.Token.Facts[0].(model.SecurityAlert)
+	want := model.SecurityAlert{ID: 9, UserID: 3, Type: "fraud_risk", Level: "high"}
+	if alert != want {
+		t.Fatalf("期望 %+v，实际 %+v", want, alert)
+	}
+}
+
+func TestLoadRulesRejectsUnregisteredAssertType(t *testing.T) {
+	e := New()
+	err := e.LoadRules([]model.Rule{{
+		Name: "未注册类型",
+		When: lockedUserRule.When,
+		Then: model.Action{Type: "assert", FactType: "Order"},
+	}})
+	if err == nil {
+		t.Fatalf("期望未注册的事实类型导致加载失败")
+	}
+}
+
+func TestUpdateFactRepropagates(t *testing.T) {
+	e := New()
+	if err := e.LoadRules([]model.Rule{lockedUserRule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	e.AddFact(model.User{ID: 1, Status: "normal"})
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("正常用户不应激活规则，实际 %d", got)
+	}
+
+	e.UpdateFact(model.User{ID: 1, Status: "locked"})
+	act, ok := e.Agenda().Next()
+	if !ok || act.Token.Facts[0].(model.User).Status != "locked" {
+		t.Fatalf("状态修改为 locked 后应以新版本激活规则，实际 %+v", act)
+	}
+
+	e.UpdateFact(model.User{ID: 1, Status: "locked", Name: "张三"})
+	e.UpdateFact(model.User{ID: 1, Status: "normal"})
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("状态恢复后激活项应被撤回，实际 %d", got)
+	}
+	if f, _ := e.GetFact("User:1"); f.(model.User).Status != "normal" {
+		t.Fatalf("工作内存应保存最新版本，实际 %+v", f)
+	}
+}
+
+func TestUpdateFactIgnoresUnchangedFact(t *testing.T) {
+	e := New()
+	if err := e.LoadRules([]model.Rule{lockedUserRule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+	e.AddFact(model.User{ID: 1, Status: "locked"})
+	e.FireAllRules()
+
+	// 内容相同的更新不重新传播，已触发的规则不会再次激活
+	e.UpdateFact(model.User{ID: 1, Status: "locked"})
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("相同内容的更新不应重新激活规则，实际 %d 个激活项", got)
+	}
+
+	// 与条件无关的字段变化仍会重新激活仍然满足条件的规则
+	e.UpdateFact(model.User{ID: 1, Status: "locked", Name: "张三"})
+	if got := e.Agenda().Size(); got != 1 {
+		t.Fatalf("事实内容变化后仍满足条件的规则应重新激活，实际 %d 个激活项", got)
+	}
+}
+
+func TestLogicalFactRetractedWithJustification(t *testing.T) {
+	e := New()
+	e.RegisterFactType("SecurityAlert", model.SecurityAlert{})
+	err := e.LoadRules([]model.Rule{{
+		Name: "大额交易逻辑警报",
+		When: []model.Condition{
+			{Type: "fact", FactType: "Transaction", Field: "Amount", Operator: ">", Value: 50000},
+		},
+		Then: model.Action{
+			Type:     "assert_logical",
+			FactType: "SecurityAlert",
+			Data:     map[string]interface{}{"ID": "$0.UserID", "UserID": "$0.UserID", "Type": "fraud_risk"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	tx1 := model.Transaction{ID: 1, UserID: 3, Amount: 60000}
+	tx2 := model.Transaction{ID: 2, UserID: 3, Amount: 70000}
+	e.AddFact(tx1)
+	e.AddFact(tx2)
+	e.FireAllRules()
+	if _, ok := e.GetFact("SecurityAlert:3"); !ok {
+		t.Fatalf("期望插入逻辑事实 SecurityAlert:3")
+	}
+
+	// 仍有 tx2 作为依据，警报保留
+	e.RetractFact(tx1)
+	if _, ok := e.GetFact("SecurityAlert:3"); !ok {
+		t.Fatalf("仍有依据时逻辑事实不应被撤回")
+	}
+
+	// 最后一个依据消失，警报自动撤回
+	e.RetractFact(tx2)
+	if _, ok := e.GetFact("SecurityAlert:3"); ok {
+		t.Fatalf("失去全部依据后逻辑事实应被撤回")
+	}
+}
+
+func TestLogicalFactSurvivesUpdateOfSupportingFact(t *testing.T) {
+	e := New()
+	err := e.LoadRules([]model.Rule{{
+		Name: "大额交易逻辑警报",
+		When: []model.Condition{
+			{Type: "fact", FactType: "Transaction", Field: "Amount", Operator: ">", Value: 50000},
+		},
+		Then: model.Action{
+			Type:     "assert_logical",
+			FactType: "SecurityAlert",
+			Data:     map[string]interface{}{"ID": "$0.UserID", "UserID": "$0.UserID", "Type": "fraud_risk"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	e.AddFact(model.Transaction{ID: 1, UserID: 3, Amount: 60000, Status: "pending"})
+	e.FireAllRules()
+
+	// 修改与条件无关的字段：交易仍然匹配，警报不应在规则重新触发前消失
+	e.UpdateFact(model.Transaction{ID: 1, UserID: 3, Amount: 60000, Status: "completed"})
+	if _, ok := e.GetFact("SecurityAlert:3"); !ok {
+		t.Fatalf("支撑事实更新后仍然匹配，逻辑事实不应被撤回")
+	}
+
+	// 修改后不再匹配：依据消失，警报撤回
+	e.UpdateFact(model.Transaction{ID: 1, UserID: 3, Amount: 100, Status: "completed"})
+	if _, ok := e.GetFact("SecurityAlert:3"); ok {
+		t.Fatalf("支撑事实不再匹配后逻辑事实应被撤回")
+	}
+}
+
+func TestJoinResolvesBoundVariable(t *testing.T) {
+	e := New()
+	err := e.LoadRules([]model.Rule{{
+		Name: "用户交易且有账户",
+		When: []model.Condition{
+			{Type: "fact", FactType: "User", Field: "Status", Operator: "==", Value: "normal", Bind: "$u"},
+			{Type: "fact", FactType: "Transaction", Field: "Amount", Operator: ">", Value: 100,
+				Join: &model.JoinClause{LeftField: "$u.ID", RightField: "UserID"}},
+			// 不使用绑定时会与上一个事实（Transaction.ID）连接
+			{Type: "fact", FactType: "Account", Field: "Status", Operator: "==", Value: "active",
+				Join: &model.JoinClause{LeftField: "$u.ID", RightField: "UserID"}},
+		},
+		Then: model.Action{Type: "log"},
+	}})
+	if err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	e.AddFact(model.User{ID: 1, Status: "normal"})
+	e.AddFact(model.Transaction{ID: 500, UserID: 1, Amount: 200})
+	e.AddFact(model.Account{ID: 7, UserID: 1, Status: "active"})
+	if got := e.Agenda().Size(); got != 1 {
+		t.Fatalf("期望通过绑定变量连接到 User，产生 1 个激活项，实际 %d", got)
+	}
+}
+
+func TestLoadRulesRejectsUnknownBinding(t *testing.T) {
+	e := New()
+	err := e.LoadRules([]model.Rule{{
+		Name: "未定义变量",
+		When: []model.Condition{
+			{Type: "fact", FactType: "User", Field: "Status", Operator: "==", Value: "normal"},
+			{Type: "fact", FactType: "Transaction", Field: "Amount", Operator: ">", Value: 100,
+				Join: &model.JoinClause{LeftField: "$x.ID", RightField: "UserID"}},
+		},
+		Then: model.Action{Type: "log"},
+	}})
+	if err == nil {
+		t.Fatalf("期望引用未定义的绑定变量导致加载失败")
+	}
+}
+
+func TestOrRuleActivatesOncePerToken(t *testing.T) {
+	e := New()
+	rule := model.Rule{
+		Name: "高风险交易",
+		When: []model.Condition{
+			{Type: "or", Any: [][]model.Condition{
+				{{Type: "fact", FactType: "Transaction", Field: "Amount", Operator: ">", Value: 50000}},
+				{{Type: "fact", FactType: "Transaction", Field: "Location", Operator: "==", Value: "海外"}},
+			}},
+		},
+		Then: model.Action{Type: "log", Message: "高风险交易"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	both := model.Transaction{ID: 1, Amount: 60000, Location: "海外"}
+	e.AddFact(both)
+	e.AddFact(model.Transaction{ID: 2, Amount: 100, Location: "海外"})
+	e.AddFact(model.Transaction{ID: 3, Amount: 100, Location: "北京"})
+	if got := e.Agenda().Size(); got != 2 {
+		t.Fatalf("同时满足两个分支的事实只应激活一次，期望 2 个激活项，实际 %d", got)
+	}
+
+	e.RetractFact(both)
+	if got := e.Agenda().Size(); got != 1 {
+		t.Fatalf("撤回后期望 1 个激活项，实际 %d", got)
+	}
+}
+
+func TestLoadRulesRejectsInconsistentOrRefs(t *testing.T) {
+	e := New()
+	rule := model.Rule{
+		Name: "引用不一致",
+		When: []model.Condition{
+			{Type: "or", Any: [][]model.Condition{
+				{{Type: "fact", FactType: "User", Field: "Status", Operator: "==", Value: "locked", Bind: "$u"}},
+				{
+					{Type: "fact", FactType: "Transaction", Field: "Amount", Operator: ">", Value: 50000},
+					{Type: "fact", FactType: "User", Field: "Status", Operator: "==", Value: "suspicious", Bind: "$u",
+						Join: &model.JoinClause{LeftField: "UserID", RightField: "ID"}},
+				},
+			}},
+		},
+		Then: model.Action{Type: "retract", Target: "$u"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err == nil {
+		t.Fatalf("$u 在各分支指向不同事实，期望加载失败")
+	}
+}
+
+func TestPatternWithMultipleConstraints(t *testing.T) {
+	e := New()
+	rule := model.Rule{
+		Name: "正常 VIP 用户",
+		When: []model.Condition{
+			{Type: "fact", FactType: "User", Field: "Level", Operator: "==", Value: "VIP",
+				Constraints: []model.Constraint{{Field: "Status", Operator: "==", Value: "normal"}}},
+		},
+		Then: model.Action{Type: "log", Message: "正常 VIP 用户"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	e.AddFact(model.User{ID: 1, Level: "VIP", Status: "normal"})
+	e.AddFact(model.User{ID: 2, Level: "VIP", Status: "locked"})
+	e.AddFact(model.User{ID: 3, Level: "normal", Status: "normal"})
+
+	act, ok := e.Agenda().Next()
+	if !ok || len(act.Token.Facts) != 1 {
+		t.Fatalf("期望单事实 Token，实际 %+v", act)
+	}
+	if user := act.Token.Facts[0].(model.User); user.ID != 1 {
+		t.Fatalf("期望匹配用户 1，实际 %d", user.ID)
+	}
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("期望只有 1 个激活项，多出 %d 个", got)
+	}
+}
+
+func TestExprJoinsBoundFact(t *testing.T) {
+	e := New()
+	e.RegisterFactType("Account", model.Account{})
+	e.RegisterFactType("Transaction", model.Transaction{})
+	rule := model.Rule{
+		Name: "超额提现",
+		When: []model.Condition{
+			{Type: "fact", FactType: "Account", Expr: `Status == "active"`, Bind: "$acct"},
+			{Type: "fact", FactType: "Transaction", Expr: `UserID == $acct.UserID && Amount > 2 * $acct.Balance && Currency in ["USD","EUR"]`},
+		},
+		Then: model.Action{Type: "log", Message: "超额提现"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	e.AddFact(model.Account{ID: 1, UserID: 7, Balance: 1000, Status: "active"})
+	e.AddFact(model.Transaction{ID: 1, UserID: 7, Amount: 2500, Currency: "USD"})
+	e.AddFact(model.Transaction{ID: 2, UserID: 7, Amount: 1500, Currency: "USD"})
+	e.AddFact(model.Transaction{ID: 3, UserID: 8, Amount: 2500, Currency: "USD"})
+	e.AddFact(model.Transaction{ID: 4, UserID: 7, Amount: 2500, Currency: "CNY"})
+
+	if got := e.Agenda().Size(); got != 1 {
+		t.Fatalf("期望 1 个激活项，实际 %d", got)
+	}
+}
+
+func TestLoadRulesRejectsUnknownOperator(t *testing.T) {
+	e := New()
+	rule := model.Rule{
+		Name: "未知运算符",
+		When: []model.Condition{
+			{Type: "fact", FactType: "User", Field: "Country", Operator: "like", Value: "K%"},
+		},
+		Then: model.Action{Type: "log"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err == nil {
+		t.Fatalf("期望未知运算符导致加载失败")
+	}
+}
+
+func TestYAMLIntegerMatchesFloatField(t *testing.T) {
+	e := New()
+	rule := model.Rule{
+		Name: "整额交易",
+		When: []model.Condition{
+			{Type: "fact", FactType: "Transaction", Field: "Amount", Operator: "==", Value: 100},
+		},
+		Then: model.Action{Type: "log"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	e.AddFact(model.Transaction{ID: 1, Amount: 100})
+	if got := e.Agenda().Size(); got != 1 {
+		t.Fatalf("int 字面量应与 float64 字段相等，期望 1 个激活项，实际 %d", got)
+	}
+}
+
+func TestLoadRulesReportsAllProblems(t *testing.T) {
+	e := New()
+	rules := []model.Rule{
+		{
+			Name: "拼写错误",
+			When: []model.Condition{
+				{Type: "fact", FactType: "Transacton", Field: "Amount", Operator: ">", Value: 100},
+			},
+			Then: model.Action{Type: "log"},
+		},
+		{
+			Name: "字段与类型错误",
+			When: []model.Condition{
+				{Type: "fact", FactType: "User", Field: "Status", Operator: "==", Value: "locked"},
+				{Type: "fact", FactType: "Transaction", Field: "Amout", Operator: ">", Value: 100,
+					Constraints: []model.Constraint{{Field: "Amount", Operator: ">", Value: "100"}}},
+			},
+			Then: model.Action{Type: "log"},
+		},
+		lockedUserRule,
+	}
+
+	err := e.LoadRules(rules)
+	var verr *builder.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("期望 *builder.ValidationError，实际 %v", err)
+	}
+	want := []struct {
+		rule      string
+		condition int
+	}{
+		{"拼写错误", 1},
+		{"字段与类型错误", 2},
+		{"字段与类型错误", 2},
+	}
+	if len(verr.Problems) != len(want) {
+		t.Fatalf("期望 %d 个问题，实际 %d:\n%v", len(want), len(verr.Problems), err)
+	}
+	for i, w := range want {
+		if p := verr.Problems[i]; p.Rule != w.rule || p.Condition != w.condition {
+			t.Fatalf("第 %d 个问题期望位于规则 '%s' 第 %d 个条件，实际 %v", i+1, w.rule, w.condition, p)
+		}
+	}
+
+	e.AddFact(model.User{ID: 1, Status: "locked"})
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("校验失败时不应构建任何规则，实际产生 %d 个激活项", got)
+	}
+}
+
+func TestConditionMatchesJSONPayload(t *testing.T) {
+	e := New()
+	rule := model.Rule{
+		Name: "大额多件订单",
+		When: []model.Condition{
+			{Type: "fact", FactType: "GenericFact", Field: "Payload.amount", Operator: ">", Value: 100,
+				Expr: "Payload.items.len >= 2 && Payload.items[0].sku == 'A1'"},
+		},
+		Then: model.Action{Type: "log", Message: "大额多件订单"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	for id, raw := range map[string]string{
+		"o1": `{"amount": 250, "items": [{"sku": "A1"}, {"sku": "B2"}]}`,
+		"o2": `{"amount": 250, "items": [{"sku": "A1"}]}`,
+		"o3": `{"amount": 50, "items": [{"sku": "A1"}, {"sku": "B2"}]}`,
+		"o4": `{"items": [{"sku": "A1"}, {"sku": "B2"}]}`,
+	} {
+		var payload map[string]any
+		if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+			t.Fatalf("解析 JSON 失败: %v", err)
+		}
+		e.AddFact(model.GenericFact{ID: id, Payload: payload})
+	}
+
+	act, ok := e.Agenda().Next()
+	if !ok {
+		t.Fatalf("期望 o1 触发规则")
+	}
+	if id := act.Token.Facts[0].Key(); id != "o1" {
+		t.Fatalf("期望匹配 o1，实际 %s", id)
+	}
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("期望只有 1 个激活项，多出 %d 个", got)
+	}
+}
+
+func TestDynamicFactsFromJSON(t *testing.T) {
+	e := New()
+	e.RegisterDynamicType("Order")
+	e.RegisterDynamicType("Alert")
+	rules := []model.Rule{
+		{
+			Name: "大额订单告警",
+			When: []model.Condition{
+				{Type: "fact", FactType: "Order", Bind: "o", Field: "amount", Operator: ">", Value: 100,
+					Expr: "items.len >= 2"},
+			},
+			Then: model.Action{Type: "assert", FactType: "Alert", Data: map[string]interface{}{"ID": "$o.ID", "level": "high"}},
+		},
+		{
+			Name: "高级别告警",
+			When: []model.Condition{
+				{Type: "fact", FactType: "Alert", Field: "level", Operator: "==", Value: "high"},
+			},
+			Then: model.Action{Type: "log", Message: "高级别告警"},
+		},
+	}
+	if err := e.LoadRules(rules); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	facts, err := model.DecodeJSON([]byte(`[
+		{"type": "Order", "id": "o1", "attrs": {"amount": 250, "items": [{"sku": "A1"}, {"sku": "B2"}]}},
+		{"type": "Order", "id": 2, "attrs": {"amount": 50, "items": [{"sku": "A1"}, {"sku": "B2"}]}},
+		{"type": "Refund", "id": "r1", "attrs": {"amount": 250, "items": [{"sku": "A1"}, {"sku": "B2"}]}}
+	]`))
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	for _, f := range facts {
+		e.AddFact(f)
+	}
+	e.FireAllRules()
+
+	alert, ok := e.GetFact("Alert:o1")
+	if !ok {
+		t.Fatalf("期望插入 Alert:o1")
+	}
+	if got := model.TypeNameOf(alert); got != "Alert" {
+		t.Fatalf("期望类型名 Alert，实际 %s", got)
+	}
+	if _, ok := e.GetFact("Alert:2"); ok {
+		t.Fatalf("小额订单不应触发告警")
+	}
+}
+
+func TestPointerFactsMatchAndModifyCopies(t *testing.T) {
+	e := New()
+	rule := model.Rule{
+		Name: "锁定用户转人工",
+		When: []model.Condition{
+			{Type: "fact", FactType: "User", Field: "Status", Operator: "==", Value: "locked"},
+		},
+		Then: model.Action{Type: "modify", Target: "$0", Data: map[string]interface{}{"Status": "review"}},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	user := &model.User{ID: 1, Status: "locked"}
+	e.AddFact(user)
+	if got := e.Agenda().Size(); got != 1 {
+		t.Fatalf("指针事实应匹配 User 条件，期望 1 个激活项，实际 %d", got)
+	}
+	e.FireAllRules()
+
+	if user.Status != "locked" {
+		t.Fatalf("modify 不应原地修改插入的指针事实，实际 %s", user.Status)
+	}
+	f, _ := e.GetFact("User:1")
+	updated, ok := f.(*model.User)
+	if !ok || updated == user || updated.Status != "review" {
+		t.Fatalf("期望工作内存保存修改后的新指针，实际 %#v", f)
+	}
+}
+
+func TestNotAndExistsReceiveRightFacts(t *testing.T) {
+	e := New()
+	join := &model.JoinClause{LeftField: "UserID", RightField: "UserID"}
+	rules := []model.Rule{
+		{
+			Name: "未绑定可信设备",
+			When: []model.Condition{
+				{Type: "fact", FactType: "Transaction", Field: "Amount", Operator: ">", Value: 10000},
+				{Type: "not", FactType: "DeviceInfo", Field: "Trusted", Operator: "==", Value: true, Join: join},
+			},
+			Then: model.Action{Type: "log", Message: "未绑定可信设备"},
+		},
+		{
+			Name: "存在失败登录",
+			When: []model.Condition{
+				{Type: "fact", FactType: "Transaction", Field: "Amount", Operator: ">", Value: 10000},
+				{Type: "exists", FactType: "LoginAttempt", Field: "Success", Operator: "==", Value: false, Join: join},
+			},
+			Then: model.Action{Type: "log", Message: "存在失败登录"},
+		},
+	}
+	if err := e.LoadRules(rules); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+	fired := func() map[string]int {
+		got := make(map[string]int)
+		for {
+			act, ok := e.Agenda().Next()
+			if !ok {
+				return got
+			}
+			got[act.RuleName]++
+		}
+	}
+
+	e.AddFact(model.Transaction{ID: 1, UserID: 1, Amount: 20000})
+	e.AddFact(model.Transaction{ID: 2, UserID: 2, Amount: 20000})
+	e.AddFact(model.DeviceInfo{DeviceID: "d1", UserID: 1, Trusted: true})
+	e.AddFact(model.DeviceInfo{DeviceID: "d2", UserID: 2, Trusted: false})
+	e.AddFact(model.LoginAttempt{ID: 1, UserID: 2, Success: false})
+
+	got := fired()
+	if got["未绑定可信设备"] != 1 {
+		t.Fatalf("期望只有用户 2 的交易未绑定可信设备，实际激活 %d 次", got["未绑定可信设备"])
+	}
+	if got["存在失败登录"] != 1 {
+		t.Fatalf("期望只有用户 2 的交易存在失败登录，实际激活 %d 次", got["存在失败登录"])
+	}
+}
+
+func TestRuleCanStartWithNotOrExists(t *testing.T) {
+	e := New()
+	rules := []model.Rule{
+		{
+			Name: "无严重告警时自动放行",
+			When: []model.Condition{
+				{Type: "not", FactType: "SecurityAlert", Field: "Level", Operator: "==", Value: "critical"},
+			},
+			Then: model.Action{Type: "log", Message: "自动放行"},
+		},
+		{
+			Name: "存在严重告警时冻结大额交易",
+			When: []model.Condition{
+				{Type: "exists", FactType: "SecurityAlert", Field: "Level", Operator: "==", Value: "critical"},
+				{Type: "fact", FactType: "Transaction", Bind: "tx", Field: "Amount", Operator: ">", Value: 10000,
+					Constraints: []model.Constraint{{Field: "Status", Operator: "!=", Value: "frozen"}}},
+			},
+			Then: model.Action{Type: "modify", Target: "$tx", Data: map[string]interface{}{"Status": "frozen"}},
+		},
+	}
+	if err := e.LoadRules(rules); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	act, ok := e.Agenda().Next()
+	if !ok || act.RuleName != "无严重告警时自动放行" || len(act.Token.Facts) != 0 {
+		t.Fatalf("期望没有任何事实时 not 规则以空 Token 激活，实际 %+v", act)
+	}
+
+	e.AddFact(model.Transaction{ID: 1, Amount: 20000, Status: "pending"})
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("没有严重告警时不应冻结交易，实际 %d 个激活项", got)
+	}
+
+	alert := model.SecurityAlert{ID: 1, Level: "critical"}
+	e.AddFact(alert)
+	e.FireAllRules()
+	if f, _ := e.GetFact("Transaction:1"); f.(model.Transaction).Status != "frozen" {
+		t.Fatalf("期望存在严重告警时交易被冻结，实际 %+v", f)
+	}
+
+	e.RetractFact(alert)
+	act, ok = e.Agenda().Next()
+	if !ok || act.RuleName != "无严重告警时自动放行" {
+		t.Fatalf("告警撤回后 not 规则应重新激活，实际 %+v", act)
+	}
+	if _, ok := e.GetFact(rete.InitialFactType); ok {
+		t.Fatalf("初始事实不应出现在工作内存中")
+	}
+}
+
+func TestAggregateJoinedToLeftToken(t *testing.T) {
+	e := New()
+	rule := model.Rule{
+		Name: "VIP 用户已完成交易合计超限",
+		When: []model.Condition{
+			{Type: "fact", FactType: "User", Bind: "u", Field: "Level", Operator: "==", Value: "VIP"},
+			{Type: "aggregate", FactType: "Transaction", Bind: "total", Field: "Status", Operator: "==", Value: "completed",
+				Join:      &model.JoinClause{LeftField: "$u.ID", RightField: "UserID"},
+				Aggregate: "sum", AggregateField: "Amount", ThresholdOperator: ">", Threshold: 10000},
+			{Type: "fact", FactType: "Account", Expr: "UserID == $u.ID && Balance < $total.Value"},
+		},
+		Then: model.Action{Type: "log", Message: "VIP 用户已完成交易合计超过余额"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	e.AddFact(model.User{ID: 1, Level: "VIP"})
+	e.AddFact(model.User{ID: 2, Level: "VIP"})
+	e.AddFact(model.Account{ID: 1, UserID: 1, Balance: 5000})
+	e.AddFact(model.Account{ID: 2, UserID: 2, Balance: 5000})
+	e.AddFact(model.Transaction{ID: 1, UserID: 1, Amount: 8000, Status: "completed"})
+	e.AddFact(model.Transaction{ID: 2, UserID: 1, Amount: 4000, Status: "pending"})
+	e.AddFact(model.Transaction{ID: 3, UserID: 2, Amount: 9000, Status: "completed"})
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("合计未超限时不应激活，实际 %d", got)
+	}
+
+	e.UpdateFact(model.Transaction{ID: 2, UserID: 1, Amount: 4000, Status: "completed"})
+	act, ok := e.Agenda().Next()
+	if !ok || act.Token.Facts[0].(model.User).ID != 1 {
+		t.Fatalf("期望用户 1 合计 12000 时激活，实际 %+v", act)
+	}
+	if total := act.Token.Facts[1].(rete.AggregateResult); total.Value != 12000 || total.Count != 2 {
+		t.Fatalf("期望聚合结果为 2 笔合计 12000，实际 %+v", total)
+	}
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("期望只有 1 个激活项，多出 %d 个", got)
+	}
+
+	e.RetractFact(model.Transaction{ID: 1})
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("合计跌破阈值后不应有激活项，实际 %d", got)
+	}
+}
+
+func TestAggregateCountsOnlyMatchingFacts(t *testing.T) {
+	e := New()
+	rule := model.Rule{
+		Name: "多次失败登录",
+		When: []model.Condition{
+			{Type: "aggregate", FactType: "LoginAttempt", Field: "Success", Operator: "==", Value: false,
+				GroupBy: "UserID", Aggregate: "count", Threshold: 3},
+		},
+		Then: model.Action{Type: "log", Message: "多次失败登录"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	e.AddFact(model.Transaction{ID: 1, UserID: 7})
+	e.AddFact(model.LoginAttempt{ID: 1, UserID: 7, Success: true})
+	e.AddFact(model.LoginAttempt{ID: 2, UserID: 7, Success: false})
+	e.AddFact(model.LoginAttempt{ID: 3, UserID: 7, Success: false})
+	e.AddFact(model.LoginAttempt{ID: 4, UserID: 8, Success: false})
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("交易、成功登录与其他用户的失败登录不应计入，实际 %d 个激活项", got)
+	}
+
+	e.AddFact(model.LoginAttempt{ID: 5, UserID: 7, Success: false})
+	act, ok := e.Agenda().Next()
+	if !ok {
+		t.Fatalf("期望用户 7 第 3 次失败登录时激活")
+	}
+	if result := act.Token.Facts[0].(rete.AggregateResult); result.GroupKey != "7" || result.Count != 3 {
+		t.Fatalf("期望用户 7 的 3 次失败登录，实际 %+v", result)
+	}
+}
+
+func TestAggregateTimeWindowDrivenByClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	clock := rete.NewPseudoClock(start)
+	e := New()
+	e.SetClock(clock)
+
+	rule := model.Rule{
+		Name: "10 分钟内多次失败登录",
+		When: []model.Condition{
+			{Type: "aggregate", FactType: "LoginAttempt", Field: "Success", Operator: "==", Value: false,
+				GroupBy: "UserID", Aggregate: "count", Threshold: 3,
+				Window: &model.Window{Duration: "10m", TimestampField: "Timestamp"}},
+		},
+		Then: model.Action{Type: "log", Message: "10 分钟内多次失败登录"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	failed := func(id int, ago time.Duration) model.LoginAttempt {
+		return model.LoginAttempt{ID: id, UserID: 7, Timestamp: start.Add(-ago).Unix()}
+	}
+	e.AddFact(failed(1, 12*time.Minute)) // 已在窗口之外
+	e.AddFact(failed(2, 5*time.Minute))
+	e.AddFact(failed(3, time.Minute))
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("窗口内只有 2 次失败登录，不应激活，实际 %d 个激活项", got)
+	}
+
+	e.AddFact(failed(4, 0))
+	if got := e.Agenda().Size(); got != 1 {
+		t.Fatalf("期望窗口内 3 次失败登录时激活 1 次，实际 %d", got)
+	}
+
+	// 10:06：5 分钟前的失败登录离开窗口，计数降为 2，激活项被撤回
+	clock.Advance(6 * time.Minute)
+	e.Tick()
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("事实离开窗口后应撤回激活项，实际 %d 个", got)
+	}
+
+	invalid := rule
+	invalid.When = []model.Condition{rule.When[0]}
+	invalid.When[0].Window = &model.Window{Length: 3, Duration: "10m"}
+	if err := New().LoadRules([]model.Rule{invalid}); err == nil {
+		t.Fatalf("同时指定 length 与 duration 的窗口应被拒绝")
+	}
+}
+
+// calendarEvent 与 dayNote 用于测试时间字段与日期字符串之间的索引连接。
+type calendarEvent struct {
+	ID int
+	At time.Time
+}
+
+func (c calendarEvent) Key() string { return fmt.Sprintf("calendarEvent:%d", c.ID) }
+
+type dayNote struct {
+	ID  int
+	Day string
+}
+
+func (d dayNote) Key() string { return fmt.Sprintf("dayNote:%d", d.ID) }
+
+func TestIndexedJoinMatchesTimeWithDateString(t *testing.T) {
+	e := New()
+	e.RegisterFactType("Ev", calendarEvent{})
+	e.RegisterFactType("Note", dayNote{})
+	rule := model.Rule{
+		Name: "当天事件的备注",
+		When: []model.Condition{
+			{Type: "fact", FactType: "Ev", Bind: "ev"},
+			{Type: "fact", FactType: "Note", Join: &model.JoinClause{LeftField: "$ev.At", RightField: "Day"}},
+		},
+		Then: model.Action{Type: "log", Message: "当天事件的备注"},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+
+	e.AddFact(calendarEvent{ID: 1, At: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)})
+	e.AddFact(dayNote{ID: 1, Day: "2024-05-01"})
+	e.AddFact(dayNote{ID: 2, Day: "2024-05-02"})
+	if got := e.Agenda().Size(); got != 1 {
+		t.Fatalf("期望时间 2024-05-01 与字符串 \"2024-05-01\" 连接成功 1 次，实际 %d", got)
+	}
+}
+
+// noCriticalAlertRule 返回以"不存在严重告警"开头的规则。
+func noCriticalAlertRule(name string) model.Rule {
+	return model.Rule{
+		Name: name,
+		When: []model.Condition{
+			{Type: "not", FactType: "SecurityAlert", Field: "Level", Operator: "==", Value: "critical"},
+		},
+		Then: model.Action{Type: "log", Message: name},
+	}
+}
+
+func TestLeadingNotRulesLoadedSeparately(t *testing.T) {
+	e := New()
+	for _, name := range []string{"规则A", "规则B"} {
+		if err := e.LoadRules([]model.Rule{noCriticalAlertRule(name)}); err != nil {
+			t.Fatalf("加载规则失败: %v", err)
+		}
+	}
+	if got := e.Agenda().Size(); got != 2 {
+		t.Fatalf("条件相同、分别加载的两条 not 规则都应激活，实际 %d 个激活项", got)
+	}
+}
+
+func TestRulesLoadedAfterFactsSeeWorkingMemory(t *testing.T) {
+	e := New()
+	if err := e.LoadRules([]model.Rule{noCriticalAlertRule("规则A")}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+	e.Agenda().Clear()
+	alert := model.SecurityAlert{ID: 1, Level: "critical"}
+	e.AddFact(alert)
+	e.AddFact(model.User{ID: 1, Status: "locked"})
+
+	// 规则B 的告警条件与规则A 相同，锁定用户规则是普通模式，两者都应看到已有事实
+	if err := e.LoadRules([]model.Rule{noCriticalAlertRule("规则B"), lockedUserRule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+	act, ok := e.Agenda().Next()
+	if !ok || act.RuleName != lockedUserRule.Name {
+		t.Fatalf("期望只有锁定用户规则匹配已有事实，实际 %+v", act)
+	}
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("存在严重告警时 not 规则不应激活，实际还有 %d 个激活项", got)
+	}
+
+	e.RetractFact(alert)
+	if got := e.Agenda().Size(); got != 2 {
+		t.Fatalf("撤回告警后规则A、规则B 都应激活，实际 %d 个激活项", got)
+	}
+}
+
+func TestSelfMatchingModifyTerminates(t *testing.T) {
+	e := New()
+	rule := model.Rule{
+		Name: "普通用户升级为 VIP",
+		When: []model.Condition{
+			{Type: "fact", FactType: "User", Field: "Status", Operator: "==", Value: "normal"},
+		},
+		Then: model.Action{Type: "modify", Target: "$0", Data: map[string]interface{}{"Level": "VIP"}},
+	}
+	if err := e.LoadRules([]model.Rule{rule}); err != nil {
+		t.Fatalf("加载规则失败: %v", err)
+	}
+	e.AddFact(model.User{ID: 1, Status: "normal", Level: "normal"})
+
+	done := make(chan struct{})
+	go func() {
+		e.FireAllRules()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("modify 后规则仍匹配自己修改的事实，FireAllRules 没有结束")
+	}
+
+	if f, _ := e.GetFact("User:1"); f.(model.User).Level != "VIP" {
+		t.Fatalf("期望用户被升级为 VIP，实际 %+v", f)
+	}
+	if got := e.Agenda().Size(); got != 0 {
+		t.Fatalf("规则不应因自己的修改重新激活，实际 %d 个激活项", got)
+	}
+
+	// 其他来源的修改仍会使规则重新激活
+	e.UpdateFact(model.User{ID: 1, Status: "normal", Level: "normal"})
+	if got := e.Agenda().Size(); got != 1 {
+		t.Fatalf("外部修改后规则应重新激活，实际 %d 个激活项", got)
+	}
+}
@@ -44,8 +44,8 @@ func main() {
 			Name:     "低优先级但高特殊性规则",
 			Salience: 50, // 与中优先级相同
 			When: []model.Condition{
-				{Type: "fact", FactType: "User", Field: "Status", Operator: "==", Value: "normal"},
-				{Type: "fact", FactType: "User", Field: "Level", Operator: "==", Value: "VIP"},
+				{Type: "fact", FactType: "User", Field: "Status", Operator: "==", Value: "normal",
+					Constraints: []model.Constraint{{Field: "Level", Operator: "==", Value: "VIP"}}},
 			},
 			Then: model.Action{Type: "log", Message: "⭐ 低优先级但高特殊性规则触发 (Salience: 50, Specificity: 2)"},
 		},
@@ -0,0 +1,38 @@
+package model
+
+import "reflect"
+
+// FieldGetter 读取事实的某个字段，ok 为 false 表示事实不是该类型。
+type FieldGetter func(f Fact) (v any, ok bool)
+
+// accessors 记录各事实类型的字段读取表：类型 -> 字段名 -> 读取函数。
+// 只在 init 阶段写入，之后只读。
+var accessors = make(map[reflect.Type]map[string]FieldGetter)
+
+// RegisterAccessors 注册 prototype 类型的字段读取表。
+//
+// 通常不需要手写：在事实类型所在包中添加
+//
+//	//go:generate go run code_for_article/ruleengine/cmd/factgen
+//
+// 运行 go generate 后，生成的代码会在 init 中为包内每个实现了 Fact 的结构体调用本函数。
+// 规则构建时优先使用这些读取函数，未注册的类型或字段回退到反射。
+func RegisterAccessors(prototype Fact, getters map[string]FieldGetter) {
+	t := reflect.TypeOf(prototype)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	accessors[t] = getters
+}
+
+// LookupAccessor 返回类型 t 中字段 field 的读取函数，t 可以是结构体或其指针类型。
+func LookupAccessor(t reflect.Type, field string) (FieldGetter, bool) {
+	if t == nil {
+		return nil, false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	getter, ok := accessors[t][field]
+	return getter, ok
+}
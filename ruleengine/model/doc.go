@@ -1,2 +1,4 @@
 // Package model 定义 Fact、Condition、Rule 等领域模型。
 package model
+
+//go:generate go run code_for_article/ruleengine/cmd/factgen
@@ -0,0 +1,199 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// AttributeFact 由属性在运行时才确定的事实实现（如 DynamicFact）：
+// 规则中字段路径的首段按 Attr 读取，加载规则时不检查字段是否存在。
+type AttributeFact interface {
+	Fact
+	Attr(name string) (v any, ok bool)
+}
+
+// DynamicFact 是无需定义结构体的动态事实，类型名与属性都在运行时给出，
+// 适合上游服务以 JSON/YAML 推送、引擎无需重新编译的场景。
+//
+// 规则的 fact_type 与 Type 匹配（需先通过 Engine.RegisterDynamicType 注册），
+// 字段路径直接从属性开始，如 amount、items[0].sku。
+type DynamicFact struct {
+	Type  string
+	ID    string
+	Attrs map[string]any
+}
+
+func (d DynamicFact) Key() string { return d.Type + ":" + d.ID }
+
+// TypeName 返回声明的类型名，而不是 Go 类型名 DynamicFact。
+func (d DynamicFact) TypeName() string { return d.Type }
+
+// Attr 返回属性 name 的值；属性中没有 ID、Type 时分别返回事实的 ID 与类型名。
+func (d DynamicFact) Attr(name string) (any, bool) {
+	if v, ok := d.Attrs[name]; ok {
+		return v, true
+	}
+	switch name {
+	case "ID":
+		return d.ID, true
+	case "Type":
+		return d.Type, true
+	}
+	return nil, false
+}
+
+// Set 设置属性 name，name 为 ID 时设置事实的 ID。规则的 assert/modify 动作通过它写入数据。
+func (d *DynamicFact) Set(name string, v any) {
+	if name == "ID" {
+		d.ID = idString(v)
+		return
+	}
+	if d.Attrs == nil {
+		d.Attrs = make(map[string]any)
+	}
+	d.Attrs[name] = v
+}
+
+// Clone 返回属性表的浅拷贝，修改副本的属性不影响原事实。
+func (d DynamicFact) Clone() DynamicFact {
+	attrs := make(map[string]any, len(d.Attrs))
+	for k, v := range d.Attrs {
+		attrs[k] = v
+	}
+	d.Attrs = attrs
+	return d
+}
+
+// dynamicFactDoc 是动态事实的序列化格式：
+//
+//	{"type": "Order", "id": "o1", "attrs": {"amount": 250, "items": [{"sku": "A1"}]}}
+type dynamicFactDoc struct {
+	Type  string         `json:"type" yaml:"type"`
+	ID    any            `json:"id" yaml:"id"` // 允许数字 ID
+	Attrs map[string]any `json:"attrs" yaml:"attrs"`
+}
+
+// jsonFactDoc 是 JSON 格式的 dynamicFactDoc：id 保留原始文本，
+// 避免数字 ID 先解码为 float64 而丢失精度或变成科学计数法。
+type jsonFactDoc struct {
+	Type  string          `json:"type"`
+	ID    json.RawMessage `json:"id"`
+	Attrs map[string]any  `json:"attrs"`
+}
+
+// DecodeJSON 解析 JSON 格式的动态事实，data 可以是单个对象或对象数组。
+// 属性中的数字解码为 float64，与规则中的整数值比较时会自动归一化；
+// 数字 ID 按原文保留，如 1000000 的 Key 为 "Order:1000000"，与 DecodeYAML 一致。
+func DecodeJSON(data []byte) ([]DynamicFact, error) {
+	var raw []jsonFactDoc
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, fmt.Errorf("解析 JSON 事实失败: %w", err)
+		}
+	} else {
+		var doc jsonFactDoc
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, fmt.Errorf("解析 JSON 事实失败: %w", err)
+		}
+		raw = append(raw, doc)
+	}
+
+	docs := make([]dynamicFactDoc, len(raw))
+	for i, doc := range raw {
+		docs[i] = dynamicFactDoc{Type: doc.Type, Attrs: doc.Attrs}
+		if len(doc.ID) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(doc.ID))
+		dec.UseNumber()
+		if err := dec.Decode(&docs[i].ID); err != nil {
+			return nil, fmt.Errorf("解析第 %d 个事实的 id 失败: %w", i+1, err)
+		}
+	}
+	return toDynamicFacts(docs)
+}
+
+// DecodeYAML 解析 YAML 格式的动态事实，data 可以是单个映射或映射列表，字段与 JSON 格式相同。
+func DecodeYAML(data []byte) ([]DynamicFact, error) {
+	var docs []dynamicFactDoc
+	if err := yaml.Unmarshal(data, &docs); err != nil {
+		var doc dynamicFactDoc
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("解析 YAML 事实失败: %w", err)
+		}
+		docs = []dynamicFactDoc{doc}
+	}
+	for i := range docs {
+		docs[i].Attrs = normalizeYAML(docs[i].Attrs).(map[string]any)
+	}
+	return toDynamicFacts(docs)
+}
+
+func toDynamicFacts(docs []dynamicFactDoc) ([]DynamicFact, error) {
+	facts := make([]DynamicFact, 0, len(docs))
+	for i, doc := range docs {
+		if doc.Type == "" {
+			return nil, fmt.Errorf("第 %d 个事实缺少 type", i+1)
+		}
+		if doc.ID == nil || doc.ID == "" {
+			return nil, fmt.Errorf("第 %d 个事实（%s）缺少 id", i+1, doc.Type)
+		}
+		facts = append(facts, DynamicFact{Type: doc.Type, ID: idString(doc.ID), Attrs: doc.Attrs})
+	}
+	return facts, nil
+}
+
+// idString 将 ID 格式化为 Key 使用的字符串：整数按十进制原样输出，
+// 整数值的浮点数不使用科学计数法，使 JSON 与 YAML 中相同的数字 ID 得到相同的 Key。
+func idString(id any) string {
+	switch v := id.(type) {
+	case string:
+		return v
+	case json.Number:
+		if !strings.ContainsAny(string(v), ".eE") {
+			return string(v) // JSON 整数字面量没有前导零，原文即规范形式
+		}
+		if f, err := v.Float64(); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return fmt.Sprint(id)
+}
+
+// normalizeYAML 将 yaml.v2 解码出的 map[interface{}]interface{} 递归转换为 map[string]any，
+// 使其与 JSON 解码结果一致，可以通过字段路径访问。
+func normalizeYAML(v any) any {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(item)
+		}
+		return m
+	case map[string]any:
+		if v == nil {
+			return map[string]any{}
+		}
+		for k, item := range v {
+			v[k] = normalizeYAML(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+		return v
+	}
+	return v
+}
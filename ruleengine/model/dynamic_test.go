@@ -0,0 +1,61 @@
+package model
+
+import "testing"
+
+func TestDecodeYAMLNormalizesMaps(t *testing.T) {
+	facts, err := DecodeYAML([]byte(`
+type: Order
+id: 42
+attrs:
+  customer:
+    city: 杭州
+  items:
+    - sku: A1
+`))
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	if len(facts) != 1 || facts[0].Key() != "Order:42" {
+		t.Fatalf("期望 1 个 Order:42 事实，实际 %+v", facts)
+	}
+	customer, ok := facts[0].Attrs["customer"].(map[string]any)
+	if !ok || customer["city"] != "杭州" {
+		t.Fatalf("期望嵌套映射转换为 map[string]any，实际 %#v", facts[0].Attrs["customer"])
+	}
+	item, ok := facts[0].Attrs["items"].([]any)[0].(map[string]any)
+	if !ok || item["sku"] != "A1" {
+		t.Fatalf("期望列表中的映射转换为 map[string]any，实际 %#v", facts[0].Attrs["items"])
+	}
+
+	if _, err := DecodeJSON([]byte(`{"id": "o1"}`)); err == nil {
+		t.Fatalf("期望缺少 type 时解码失败")
+	}
+}
+
+func TestDecodeJSONKeepsNumericIDs(t *testing.T) {
+	facts, err := DecodeJSON([]byte(`[
+		{"type": "Order", "id": 1000000, "attrs": {"amount": 1}},
+		{"type": "Order", "id": 9007199254740993},
+		{"type": "Order", "id": 9007199254740992}
+	]`))
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	want := []string{"Order:1000000", "Order:9007199254740993", "Order:9007199254740992"}
+	for i, f := range facts {
+		if f.Key() != want[i] {
+			t.Fatalf("期望第 %d 个事实的 Key 为 %s，实际 %s", i+1, want[i], f.Key())
+		}
+	}
+	if _, ok := facts[0].Attrs["amount"].(float64); !ok {
+		t.Fatalf("期望属性中的数字仍解码为 float64，实际 %T", facts[0].Attrs["amount"])
+	}
+
+	yamlFacts, err := DecodeYAML([]byte("type: Order\nid: 1000000\n"))
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	if yamlFacts[0].Key() != facts[0].Key() {
+		t.Fatalf("期望 JSON 与 YAML 的 Key 一致，实际 %s 与 %s", facts[0].Key(), yamlFacts[0].Key())
+	}
+}
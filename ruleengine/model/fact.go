@@ -1,10 +1,18 @@
 package model
 
+import "reflect"
+
 // Fact 是所有业务实体插入规则引擎前需实现的接口。
 // Key 必须在工作内存中唯一，用于快速定位与撤回。
 // 建议使用业务主键或复合键（如 "User:42"）。
 //
-// 业务侧可直接在其结构体上实现 Key() 方法，或使用 GenericFact 包装。
+// 业务侧可直接在其结构体上实现 Key() 方法，或使用 GenericFact 包装；
+// 类型在运行时才确定的事实使用 DynamicFact（见 DecodeJSON / DecodeYAML）。
+//
+// 事实可以是值（User{}）或指针（&User{}），两者按相同的类型名匹配规则。
+// 引擎的各级内存与 Token 直接保存插入的事实，不做拷贝：指针事实插入后不能原地修改，
+// 否则撤回时按修改后的字段重新求值，无法找到此前匹配的节点内存。
+// 修改事实请构造新值（或新指针）并调用 UpdateFact，规则中的 modify 动作即按此方式复制后修改。
 
 type Fact interface {
 	Key() string
@@ -19,3 +27,43 @@ type GenericFact struct {
 }
 
 func (g GenericFact) Key() string { return g.ID }
+
+// TypeNamer 由自行声明类型名的事实实现（如 DynamicFact），TypeNameOf 优先使用它。
+type TypeNamer interface {
+	TypeName() string
+}
+
+// TypeNameOf 返回事实的类型名，规则中的 fact_type 即与之匹配。
+// 事实实现了 TypeNamer 时使用其声明的类型名，否则使用 Go 类型名；
+// 指针事实（如 &User{}）与值事实的类型名相同。
+func TypeNameOf(f Fact) string {
+	if n, ok := f.(TypeNamer); ok {
+		return n.TypeName()
+	}
+	return elemType(reflect.TypeOf(f)).Name()
+}
+
+// QualifiedTypeNameOf 返回带包路径的类型名，如 "code_for_article/ruleengine/model.User"，
+// 用于区分不同包中的同名类型。实现了 TypeNamer 的事实返回其声明的类型名。
+func QualifiedTypeNameOf(f Fact) string {
+	if n, ok := f.(TypeNamer); ok {
+		return n.TypeName()
+	}
+	return QualifiedTypeName(reflect.TypeOf(f))
+}
+
+// QualifiedTypeName 返回类型 t（指针取其元素类型）带包路径的名称。
+func QualifiedTypeName(t reflect.Type) string {
+	t = elemType(t)
+	if t.PkgPath() == "" {
+		return t.Name()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,478 @@
+// Code generated by factgen; DO NOT EDIT.
+
+package model
+
+func init() {
+	RegisterAccessors(Account{}, map[string]FieldGetter{
+		"ID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Account:
+				return v.ID, true
+			case *Account:
+				return v.ID, true
+			}
+			return nil, false
+		},
+		"UserID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Account:
+				return v.UserID, true
+			case *Account:
+				return v.UserID, true
+			}
+			return nil, false
+		},
+		"Balance": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Account:
+				return v.Balance, true
+			case *Account:
+				return v.Balance, true
+			}
+			return nil, false
+		},
+		"Currency": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Account:
+				return v.Currency, true
+			case *Account:
+				return v.Currency, true
+			}
+			return nil, false
+		},
+		"Status": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Account:
+				return v.Status, true
+			case *Account:
+				return v.Status, true
+			}
+			return nil, false
+		},
+	})
+	RegisterAccessors(Cart{}, map[string]FieldGetter{
+		"ID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Cart:
+				return v.ID, true
+			case *Cart:
+				return v.ID, true
+			}
+			return nil, false
+		},
+		"UserID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Cart:
+				return v.UserID, true
+			case *Cart:
+				return v.UserID, true
+			}
+			return nil, false
+		},
+		"TotalValue": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Cart:
+				return v.TotalValue, true
+			case *Cart:
+				return v.TotalValue, true
+			}
+			return nil, false
+		},
+	})
+	RegisterAccessors(DeviceInfo{}, map[string]FieldGetter{
+		"DeviceID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case DeviceInfo:
+				return v.DeviceID, true
+			case *DeviceInfo:
+				return v.DeviceID, true
+			}
+			return nil, false
+		},
+		"UserID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case DeviceInfo:
+				return v.UserID, true
+			case *DeviceInfo:
+				return v.UserID, true
+			}
+			return nil, false
+		},
+		"Trusted": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case DeviceInfo:
+				return v.Trusted, true
+			case *DeviceInfo:
+				return v.Trusted, true
+			}
+			return nil, false
+		},
+		"LastSeen": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case DeviceInfo:
+				return v.LastSeen, true
+			case *DeviceInfo:
+				return v.LastSeen, true
+			}
+			return nil, false
+		},
+		"DeviceType": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case DeviceInfo:
+				return v.DeviceType, true
+			case *DeviceInfo:
+				return v.DeviceType, true
+			}
+			return nil, false
+		},
+	})
+	RegisterAccessors(DynamicFact{}, map[string]FieldGetter{
+		"Type": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case DynamicFact:
+				return v.Type, true
+			case *DynamicFact:
+				return v.Type, true
+			}
+			return nil, false
+		},
+		"ID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case DynamicFact:
+				return v.ID, true
+			case *DynamicFact:
+				return v.ID, true
+			}
+			return nil, false
+		},
+		"Attrs": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case DynamicFact:
+				return v.Attrs, true
+			case *DynamicFact:
+				return v.Attrs, true
+			}
+			return nil, false
+		},
+	})
+	RegisterAccessors(FailedAttempt{}, map[string]FieldGetter{
+		"ID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case FailedAttempt:
+				return v.ID, true
+			case *FailedAttempt:
+				return v.ID, true
+			}
+			return nil, false
+		},
+		"UserID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case FailedAttempt:
+				return v.UserID, true
+			case *FailedAttempt:
+				return v.UserID, true
+			}
+			return nil, false
+		},
+		"Type": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case FailedAttempt:
+				return v.Type, true
+			case *FailedAttempt:
+				return v.Type, true
+			}
+			return nil, false
+		},
+		"Count": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case FailedAttempt:
+				return v.Count, true
+			case *FailedAttempt:
+				return v.Count, true
+			}
+			return nil, false
+		},
+	})
+	RegisterAccessors(GenericFact{}, map[string]FieldGetter{
+		"ID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case GenericFact:
+				return v.ID, true
+			case *GenericFact:
+				return v.ID, true
+			}
+			return nil, false
+		},
+		"Payload": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case GenericFact:
+				return v.Payload, true
+			case *GenericFact:
+				return v.Payload, true
+			}
+			return nil, false
+		},
+	})
+	RegisterAccessors(LoginAttempt{}, map[string]FieldGetter{
+		"ID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case LoginAttempt:
+				return v.ID, true
+			case *LoginAttempt:
+				return v.ID, true
+			}
+			return nil, false
+		},
+		"UserID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case LoginAttempt:
+				return v.UserID, true
+			case *LoginAttempt:
+				return v.UserID, true
+			}
+			return nil, false
+		},
+		"Success": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case LoginAttempt:
+				return v.Success, true
+			case *LoginAttempt:
+				return v.Success, true
+			}
+			return nil, false
+		},
+		"Timestamp": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case LoginAttempt:
+				return v.Timestamp, true
+			case *LoginAttempt:
+				return v.Timestamp, true
+			}
+			return nil, false
+		},
+		"IP": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case LoginAttempt:
+				return v.IP, true
+			case *LoginAttempt:
+				return v.IP, true
+			}
+			return nil, false
+		},
+		"Location": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case LoginAttempt:
+				return v.Location, true
+			case *LoginAttempt:
+				return v.Location, true
+			}
+			return nil, false
+		},
+	})
+	RegisterAccessors(SecurityAlert{}, map[string]FieldGetter{
+		"ID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case SecurityAlert:
+				return v.ID, true
+			case *SecurityAlert:
+				return v.ID, true
+			}
+			return nil, false
+		},
+		"UserID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case SecurityAlert:
+				return v.UserID, true
+			case *SecurityAlert:
+				return v.UserID, true
+			}
+			return nil, false
+		},
+		"Type": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case SecurityAlert:
+				return v.Type, true
+			case *SecurityAlert:
+				return v.Type, true
+			}
+			return nil, false
+		},
+		"Message": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case SecurityAlert:
+				return v.Message, true
+			case *SecurityAlert:
+				return v.Message, true
+			}
+			return nil, false
+		},
+		"Level": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case SecurityAlert:
+				return v.Level, true
+			case *SecurityAlert:
+				return v.Level, true
+			}
+			return nil, false
+		},
+	})
+	RegisterAccessors(Transaction{}, map[string]FieldGetter{
+		"ID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Transaction:
+				return v.ID, true
+			case *Transaction:
+				return v.ID, true
+			}
+			return nil, false
+		},
+		"UserID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Transaction:
+				return v.UserID, true
+			case *Transaction:
+				return v.UserID, true
+			}
+			return nil, false
+		},
+		"Amount": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Transaction:
+				return v.Amount, true
+			case *Transaction:
+				return v.Amount, true
+			}
+			return nil, false
+		},
+		"Currency": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Transaction:
+				return v.Currency, true
+			case *Transaction:
+				return v.Currency, true
+			}
+			return nil, false
+		},
+		"Type": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Transaction:
+				return v.Type, true
+			case *Transaction:
+				return v.Type, true
+			}
+			return nil, false
+		},
+		"Status": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Transaction:
+				return v.Status, true
+			case *Transaction:
+				return v.Status, true
+			}
+			return nil, false
+		},
+		"Location": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case Transaction:
+				return v.Location, true
+			case *Transaction:
+				return v.Location, true
+			}
+			return nil, false
+		},
+	})
+	RegisterAccessors(User{}, map[string]FieldGetter{
+		"ID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case User:
+				return v.ID, true
+			case *User:
+				return v.ID, true
+			}
+			return nil, false
+		},
+		"Name": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case User:
+				return v.Name, true
+			case *User:
+				return v.Name, true
+			}
+			return nil, false
+		},
+		"Status": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case User:
+				return v.Status, true
+			case *User:
+				return v.Status, true
+			}
+			return nil, false
+		},
+		"Level": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case User:
+				return v.Level, true
+			case *User:
+				return v.Level, true
+			}
+			return nil, false
+		},
+		"Country": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case User:
+				return v.Country, true
+			case *User:
+				return v.Country, true
+			}
+			return nil, false
+		},
+	})
+	RegisterAccessors(UserProfile{}, map[string]FieldGetter{
+		"UserID": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case UserProfile:
+				return v.UserID, true
+			case *UserProfile:
+				return v.UserID, true
+			}
+			return nil, false
+		},
+		"RegistrationAge": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case UserProfile:
+				return v.RegistrationAge, true
+			case *UserProfile:
+				return v.RegistrationAge, true
+			}
+			return nil, false
+		},
+		"ActivityLevel": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case UserProfile:
+				return v.ActivityLevel, true
+			case *UserProfile:
+				return v.ActivityLevel, true
+			}
+			return nil, false
+		},
+		"RiskScore": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case UserProfile:
+				return v.RiskScore, true
+			case *UserProfile:
+				return v.RiskScore, true
+			}
+			return nil, false
+		},
+		"HomeLocation": func(f Fact) (any, bool) {
+			switch v := f.(type) {
+			case UserProfile:
+				return v.HomeLocation, true
+			case *UserProfile:
+				return v.HomeLocation, true
+			}
+			return nil, false
+		},
+	})
+}
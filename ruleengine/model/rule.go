@@ -16,28 +16,73 @@ type Rule struct {
 
 // Condition 表示规则的一个条件子句。
 type Condition struct {
-	Type     string      `yaml:"type" json:"type"`           // "fact", "not", "exists", "aggregate"
+	Type     string      `yaml:"type" json:"type"`           // "fact", "not", "exists", "aggregate", "or"
 	FactType string      `yaml:"fact_type" json:"fact_type"` // 事实类型，如 "User", "Order"
 	Field    string      `yaml:"field,omitempty" json:"field,omitempty"`
-	Operator string      `yaml:"operator,omitempty" json:"operator,omitempty"` // "==", ">", "<", ">=", "<=", "!="
+	Operator string      `yaml:"operator,omitempty" json:"operator,omitempty"` // "==", "!=", ">", ">=", "<", "<=", "in", "not in", "contains", "matches", "starts_with", "between", "is_null", "is_not_null"
 	Value    interface{} `yaml:"value,omitempty" json:"value,omitempty"`
 	Join     *JoinClause `yaml:"join,omitempty" json:"join,omitempty"` // 用于连接条件
+	Bind     string      `yaml:"bind,omitempty" json:"bind,omitempty"` // 绑定变量名，如 "$p"，供后续条件与动作引用
+
+	// Expr 是条件表达式，如 `Amount > 2 * $acct.Balance && Currency in ["USD","EUR"]`，与其他约束按 AND 组合
+	Expr string `yaml:"expr,omitempty" json:"expr,omitempty"`
+
+	// Constraints 是同一事实需同时满足的其他字段约束，与 Field/Operator/Value 按 AND 组合
+	Constraints []Constraint `yaml:"constraints,omitempty" json:"constraints,omitempty"`
+
+	// 析取（or）相关：Any 中每个分支是一组按 AND 组合的条件，任一分支满足即可
+	Any [][]Condition `yaml:"any,omitempty" json:"any,omitempty"`
 
 	// 聚合相关
-	GroupBy   string `yaml:"group_by,omitempty" json:"group_by,omitempty"`
-	Aggregate string `yaml:"aggregate,omitempty" json:"aggregate,omitempty"` // "count", "sum"
-	Threshold int    `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+	GroupBy           string  `yaml:"group_by,omitempty" json:"group_by,omitempty"`
+	Aggregate         string  `yaml:"aggregate,omitempty" json:"aggregate,omitempty"`                   // "count", "sum", "avg", "min", "max", "count_distinct"
+	AggregateField    string  `yaml:"aggregate_field,omitempty" json:"aggregate_field,omitempty"`       // 被聚合的字段，count 可省略
+	ThresholdOperator string  `yaml:"threshold_operator,omitempty" json:"threshold_operator,omitempty"` // 聚合结果与 Threshold 的比较符，默认 ">="
+	Threshold         float64 `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+	Window            *Window `yaml:"window,omitempty" json:"window,omitempty"` // 只聚合窗口内的事实
+}
+
+// Window 定义聚合条件的窗口，Length（按数量）与 Duration（按时间）二选一。
+// 事实离开窗口时从聚合结果中反向扣除；时间窗口由引擎时钟驱动，见 Engine.SetClock 与 Engine.Tick。
+type Window struct {
+	Type     string `yaml:"type,omitempty" json:"type,omitempty"`         // "sliding"（默认）或 "tumbling"
+	Length   int    `yaml:"length,omitempty" json:"length,omitempty"`     // 窗口容纳的事实数
+	Duration string `yaml:"duration,omitempty" json:"duration,omitempty"` // 窗口时长，如 "10m"
+
+	// TimestampField 是事实的事件时间字段，可为 time.Time、Unix 秒数或时间字符串；
+	// 省略时以事实插入时的引擎时钟时间为准。
+	TimestampField string `yaml:"timestamp_field,omitempty" json:"timestamp_field,omitempty"`
+}
+
+// Constraint 是模式中对事实单个字段的约束。
+type Constraint struct {
+	Field    string      `yaml:"field" json:"field"`
+	Operator string      `yaml:"operator" json:"operator"`
+	Value    interface{} `yaml:"value,omitempty" json:"value,omitempty"`
 }
 
 // JoinClause 定义两个条件之间的连接关系。
+// LeftField 可写作 "$p.UserID" 引用绑定变量 p 对应事实的字段；
+// 不带 "$" 时引用前一个产生事实的条件。RightField 为当前条件事实的字段。
 type JoinClause struct {
 	LeftField  string `yaml:"left_field" json:"left_field"`
 	RightField string `yaml:"right_field" json:"right_field"`
 }
 
 // Action 定义规则触发时的执行动作。
+//
+// assert / retract / modify 会修改工作内存，从而驱动前向链式推理：
+//   - assert: 按 FactType 创建新事实，字段取自 Data。
+//   - assert_logical: 同 assert，但插入的是逻辑事实，触发规则的匹配被撤回时自动撤回。
+//   - retract: 撤回 Target 指向的匹配事实。
+//   - modify: 复制 Target 指向的匹配事实，按 Data 修改字段后替换原事实。
+//
+// Data 中以 "$" 开头的字符串引用匹配到的事实，如 "$0.UserID" 表示第 1 个事实的 UserID 字段，
+// "$1" 表示第 2 个事实本身；也可使用条件中 bind 的变量名，如 "$p.UserID"。
 type Action struct {
-	Type    string                 `yaml:"type" json:"type"` // "log", "assert", "callback"
-	Message string                 `yaml:"message,omitempty" json:"message,omitempty"`
-	Data    map[string]interface{} `yaml:"data,omitempty" json:"data,omitempty"`
+	Type     string                 `yaml:"type" json:"type"` // "log", "callback", "assert", "assert_logical", "retract", "modify"
+	Message  string                 `yaml:"message,omitempty" json:"message,omitempty"`
+	FactType string                 `yaml:"fact_type,omitempty" json:"fact_type,omitempty"` // assert/assert_logical 创建的事实类型
+	Target   string                 `yaml:"target,omitempty" json:"target,omitempty"`       // retract/modify 的目标，如 "$0"
+	Data     map[string]interface{} `yaml:"data,omitempty" json:"data,omitempty"`
 }
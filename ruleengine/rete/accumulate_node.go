@@ -0,0 +1,147 @@
+package rete
+
+import "code_for_article/ruleengine/model"
+
+// AccumulateNode 对每个左侧 Token 聚合与之连接的右侧事实，例如
+// "VIP 用户已完成订单的金额之和 > X"：左侧 Token 为用户，右侧事实为订单，
+// JoinFunc 判断订单是否属于该用户。
+//
+// 工作流程:
+// 1. 左侧 Token (t) 到达:
+//   - 存入 leftTokens，为其创建累加器，累加 rightFacts 中所有满足 join(t, f) 的事实。
+//   - 结果满足约束时，向下游传播 t 追加聚合结果（AggregateResult）后的 Token。
+//
+// 2. 右侧 Fact (f) 到达或撤回:
+//   - 对每个满足 join(t, f) 的左侧 Token 做（反向）累加，并重新判断约束。
+//   - 结果由不满足变为满足：传播追加结果后的 Token；由满足变为不满足：撤回该 Token；
+//     仍然满足但结果变化：先撤回旧结果的 Token，再传播新结果的 Token，下游总能看到最新的值。
+//
+// 3. 左侧 Token 撤回: 撤回其已传播的 Token 并丢弃累加器。
+//
+// 没有任何匹配事实的 Token 以空分组的结果（count、sum 为 0）判断约束，
+// 因此 "count < 1" 这类约束对没有匹配事实的 Token 成立。
+// 与 BetaNode 一样，可通过 JoinIndex 为左右内存建立哈希索引。
+type AccumulateNode struct {
+	baseNode
+	spec       AggregateSpec // 不使用 GroupBy，分组即左侧 Token
+	join       JoinFunc
+	index      *JoinIndex
+	leftTokens *BetaMemory
+	rightFacts *AlphaMemory
+	states     map[string]*accumulateState // token.hash -> 累加状态
+}
+
+// accumulateState 是一个左侧 Token 的累加状态。
+type accumulateState struct {
+	token  Token
+	acc    Accumulator
+	count  int
+	result *AggregateResult // 已传播的聚合结果，未传播时为 nil
+}
+
+// NewAccumulateNode 创建一个按 spec 聚合、以 j 连接左右输入的 AccumulateNode。
+func NewAccumulateNode(spec AggregateSpec, j JoinFunc) *AccumulateNode {
+	return NewIndexedAccumulateNode(spec, j, nil)
+}
+
+// NewIndexedAccumulateNode 创建一个按 index 建立哈希索引的 AccumulateNode。
+func NewIndexedAccumulateNode(spec AggregateSpec, j JoinFunc, index *JoinIndex) *AccumulateNode {
+	left, right := newJoinMemories(index)
+	return &AccumulateNode{
+		spec:       spec,
+		join:       j,
+		index:      index,
+		leftTokens: left,
+		rightFacts: right,
+		states:     make(map[string]*accumulateState),
+	}
+}
+
+func (a *AccumulateNode) AssertToken(t Token) {
+	if !a.leftTokens.Add(t) {
+		return
+	}
+
+	state := &accumulateState{token: t, acc: a.spec.Accumulator()}
+	for _, f := range rightCandidates(a.index, a.rightFacts, t) {
+		if a.join(t, f) {
+			state.acc.Add(a.value(f))
+			state.count++
+		}
+	}
+	a.states[t.Hash()] = state
+	a.update(state)
+}
+
+func (a *AccumulateNode) RetractToken(t Token) {
+	if !a.leftTokens.Retract(t) {
+		return
+	}
+	state, ok := a.states[t.Hash()]
+	if !ok {
+		return
+	}
+	delete(a.states, t.Hash())
+	if state.result != nil {
+		a.propagateRetractToken(extendToken(state.token, *state.result))
+	}
+}
+
+func (a *AccumulateNode) AssertFact(f model.Fact) {
+	if !a.rightFacts.Add(f) {
+		return
+	}
+	for _, t := range leftCandidates(a.index, a.leftTokens, f) {
+		if a.join(t, f) {
+			state := a.states[t.Hash()]
+			state.acc.Add(a.value(f))
+			state.count++
+			a.update(state)
+		}
+	}
+}
+
+func (a *AccumulateNode) RetractFact(f model.Fact) {
+	if !a.rightFacts.Retract(f) {
+		return
+	}
+	for _, t := range leftCandidates(a.index, a.leftTokens, f) {
+		if a.join(t, f) {
+			state := a.states[t.Hash()]
+			state.acc.Remove(a.value(f))
+			state.count--
+			a.update(state)
+		}
+	}
+}
+
+// value 提取事实中被聚合的字段值。
+func (a *AccumulateNode) value(f model.Fact) interface{} {
+	if a.spec.Value == nil {
+		return nil
+	}
+	return a.spec.Value(f)
+}
+
+// update 根据 Token 当前的聚合结果决定断言、撤回或替换下游 Token。
+func (a *AccumulateNode) update(state *accumulateState) {
+	result := AggregateResult{
+		GroupKey: state.token.Hash(),
+		Function: a.spec.Function,
+		Count:    state.count,
+		Value:    state.acc.Result(),
+	}
+	holds := a.spec.Test(result.Value)
+
+	if prev := state.result; prev != nil {
+		if holds && *prev == result {
+			return // 结果未变化
+		}
+		state.result = nil
+		a.propagateRetractToken(extendToken(state.token, *prev))
+	}
+	if holds {
+		state.result = &result
+		a.propagateAssertToken(extendToken(state.token, result))
+	}
+}
@@ -0,0 +1,55 @@
+package rete
+
+import (
+	"testing"
+
+	"code_for_article/ruleengine/model"
+)
+
+func TestAccumulateNodeTracksEachLeftToken(t *testing.T) {
+	sum, _ := LookupAccumulator("sum")
+	node := NewAccumulateNode(AggregateSpec{
+		Function:    "sum",
+		Value:       func(f model.Fact) interface{} { return f.(model.Transaction).Amount },
+		Accumulator: sum,
+		Test:        func(result float64) bool { return result > 1000 },
+	}, func(t Token, f model.Fact) bool {
+		return t.Facts[0].(model.User).ID == f.(model.Transaction).UserID
+	})
+	rec := &recorder{}
+	node.AddChild(rec)
+
+	alice := NewToken([]model.Fact{model.User{ID: 1}})
+	bob := NewToken([]model.Fact{model.User{ID: 2}})
+	node.AssertToken(alice)
+	node.AssertToken(bob)
+
+	t1 := model.Transaction{ID: 1, UserID: 1, Amount: 800}
+	t2 := model.Transaction{ID: 2, UserID: 1, Amount: 500}
+	node.AssertFact(t1)
+	node.AssertFact(model.Transaction{ID: 3, UserID: 2, Amount: 900})
+	if len(rec.asserted) != 0 {
+		t.Fatalf("合计未超过 1000 时不应传播，实际 %d", len(rec.asserted))
+	}
+
+	node.AssertFact(t2)
+	if len(rec.asserted) != 1 {
+		t.Fatalf("期望用户 1 合计 1300 时传播 1 次，实际 %d", len(rec.asserted))
+	}
+	got := rec.asserted[0]
+	if len(got.Facts) != 2 || got.Facts[0].(model.User).ID != 1 || got.Facts[1].(AggregateResult).Value != 1300 {
+		t.Fatalf("期望传播 [用户 1, 合计 1300]，实际 %+v", got.Facts)
+	}
+
+	// 结果变化但仍满足约束：撤回旧结果，传播新结果
+	node.AssertFact(model.Transaction{ID: 4, UserID: 1, Amount: 100})
+	if len(rec.retracted) != 1 || len(rec.asserted) != 2 || rec.asserted[1].Facts[1].(AggregateResult).Value != 1400 {
+		t.Fatalf("期望以合计 1400 替换旧结果，实际断言 %d 次、撤回 %d 次", len(rec.asserted), len(rec.retracted))
+	}
+
+	// 跌破约束后撤回
+	node.RetractFact(t1)
+	if len(rec.retracted) != 2 || len(rec.asserted) != 2 {
+		t.Fatalf("期望合计 600 时撤回，实际断言 %d 次、撤回 %d 次", len(rec.asserted), len(rec.retracted))
+	}
+}
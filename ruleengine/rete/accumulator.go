@@ -0,0 +1,179 @@
+package rete
+
+import (
+	"fmt"
+	"math"
+)
+
+// Accumulator 定义了可增量计算、且支持反向计算（撤回）的聚合函数。
+// AggregateNode 为每个分组维护一个 Accumulator 实例。
+//
+// Add/Remove 接收的是被聚合字段的值（count 可忽略该值），
+// 同一个值的 Add 与 Remove 必须成对出现，以保证撤回后结果正确。
+type Accumulator interface {
+	Add(v interface{})
+	Remove(v interface{})
+	Result() float64
+}
+
+// AccumulatorFactory 为新分组创建 Accumulator。
+type AccumulatorFactory func() Accumulator
+
+// accumulators 是内置聚合函数的注册表，key 为 YAML 中 aggregate 字段的取值。
+var accumulators = map[string]AccumulatorFactory{
+	"count":          func() Accumulator { return &countAcc{} },
+	"sum":            func() Accumulator { return &sumAcc{} },
+	"avg":            func() Accumulator { return &avgAcc{} },
+	"min":            func() Accumulator { return newExtremumAcc(false) },
+	"max":            func() Accumulator { return newExtremumAcc(true) },
+	"count_distinct": func() Accumulator { return &distinctAcc{values: make(map[string]int)} },
+}
+
+// LookupAccumulator 根据函数名返回内置聚合函数。
+func LookupAccumulator(name string) (AccumulatorFactory, bool) {
+	f, ok := accumulators[name]
+	return f, ok
+}
+
+// countAcc 统计事实数量。
+type countAcc struct{ n int }
+
+func (c *countAcc) Add(interface{})    { c.n++ }
+func (c *countAcc) Remove(interface{}) { c.n-- }
+func (c *countAcc) Result() float64    { return float64(c.n) }
+
+// sumAcc 对数值字段求和，非数值被忽略。
+type sumAcc struct{ sum float64 }
+
+func (s *sumAcc) Add(v interface{}) {
+	if f, ok := toNumber(v); ok {
+		s.sum += f
+	}
+}
+
+func (s *sumAcc) Remove(v interface{}) {
+	if f, ok := toNumber(v); ok {
+		s.sum -= f
+	}
+}
+
+func (s *sumAcc) Result() float64 { return s.sum }
+
+// avgAcc 对数值字段求平均值，非数值被忽略。
+type avgAcc struct {
+	sum float64
+	n   int
+}
+
+func (a *avgAcc) Add(v interface{}) {
+	if f, ok := toNumber(v); ok {
+		a.sum += f
+		a.n++
+	}
+}
+
+func (a *avgAcc) Remove(v interface{}) {
+	if f, ok := toNumber(v); ok {
+		a.sum -= f
+		a.n--
+	}
+}
+
+func (a *avgAcc) Result() float64 {
+	if a.n == 0 {
+		return 0
+	}
+	return a.sum / float64(a.n)
+}
+
+// extremumAcc 计算最小值或最大值。
+// 为了支持撤回，它保存每个值出现的次数，在取结果时再扫描求极值。
+type extremumAcc struct {
+	max    bool
+	values map[float64]int
+}
+
+func newExtremumAcc(max bool) *extremumAcc {
+	return &extremumAcc{max: max, values: make(map[float64]int)}
+}
+
+func (e *extremumAcc) Add(v interface{}) {
+	if f, ok := toNumber(v); ok {
+		e.values[f]++
+	}
+}
+
+func (e *extremumAcc) Remove(v interface{}) {
+	if f, ok := toNumber(v); ok {
+		e.values[f]--
+		if e.values[f] <= 0 {
+			delete(e.values, f)
+		}
+	}
+}
+
+func (e *extremumAcc) Result() float64 {
+	if len(e.values) == 0 {
+		return 0
+	}
+	result := math.Inf(1)
+	if e.max {
+		result = math.Inf(-1)
+	}
+	for v := range e.values {
+		if (e.max && v > result) || (!e.max && v < result) {
+			result = v
+		}
+	}
+	return result
+}
+
+// distinctAcc 统计字段不同取值的数量。
+type distinctAcc struct {
+	values map[string]int
+}
+
+func (d *distinctAcc) Add(v interface{}) {
+	d.values[fmt.Sprint(v)]++
+}
+
+func (d *distinctAcc) Remove(v interface{}) {
+	k := fmt.Sprint(v)
+	d.values[k]--
+	if d.values[k] <= 0 {
+		delete(d.values, k)
+	}
+}
+
+func (d *distinctAcc) Result() float64 { return float64(len(d.values)) }
+
+// toNumber 将常见数值类型转换为 float64。
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package rete
+
+import "code_for_article/ruleengine/model"
+
+// AlphaNode 与 AggregateNode 会同时向子节点传播 Fact 与单元素 Token，
+// 而 BetaNode、NotNode、ExistsNode 通过 AssertFact/AssertToken 区分右输入与左输入。
+// 直接 AddChild 会让同一个事实既进入左内存又进入右内存，
+// 因此连接双输入节点时，用适配器限定上游只作为某一侧的输入。
+
+// LeftAdapter 只向目标节点转发 Token，使上游成为目标节点的左输入。
+type LeftAdapter struct {
+	target Node
+}
+
+// NewLeftAdapter 创建左输入适配器。
+func NewLeftAdapter(target Node) *LeftAdapter { return &LeftAdapter{target: target} }
+
+func (l *LeftAdapter) AssertFact(f model.Fact)  {}
+func (l *LeftAdapter) RetractFact(f model.Fact) {}
+func (l *LeftAdapter) AssertToken(t Token)      { l.target.AssertToken(t) }
+func (l *LeftAdapter) RetractToken(t Token)     { l.target.RetractToken(t) }
+
+// AddChild 适配器没有自己的子节点，子节点应挂在目标节点上。
+func (l *LeftAdapter) AddChild(n Node) { l.target.AddChild(n) }
+
+// RightAdapter 只向目标节点转发 Fact，使上游成为目标节点的右输入。
+type RightAdapter struct {
+	target Node
+}
+
+// NewRightAdapter 创建右输入适配器。
+func NewRightAdapter(target Node) *RightAdapter { return &RightAdapter{target: target} }
+
+func (r *RightAdapter) AssertFact(f model.Fact)  { r.target.AssertFact(f) }
+func (r *RightAdapter) RetractFact(f model.Fact) { r.target.RetractFact(f) }
+func (r *RightAdapter) AssertToken(t Token)      {}
+func (r *RightAdapter) RetractToken(t Token)     {}
+
+// AddChild 适配器没有自己的子节点，子节点应挂在目标节点上。
+func (r *RightAdapter) AddChild(n Node) { r.target.AddChild(n) }
+
+// InitialFactType 是初始事实的类型名。
+const InitialFactType = "InitialFact"
+
+// InitialFact 是引擎管理的初始事实：加载规则后插入一次，不在工作内存中可见。
+// 以 not / exists 开头的规则没有上游 Token，Builder 为其创建接收初始事实的 AlphaNode，
+// 经 InitialAdapter 转为空 Token 作为左输入，使"不存在维护窗口"这类条件也能在没有其他事实时成立。
+type InitialFact struct{}
+
+func (InitialFact) Key() string      { return InitialFactType }
+func (InitialFact) TypeName() string { return InitialFactType }
+
+// InitialAdapter 将初始事实的 Token 转为空 Token 转发给目标节点的左输入，
+// 使下游 Token 中不包含初始事实，绑定变量的位置与普通规则一致。
+type InitialAdapter struct {
+	target Node
+}
+
+// NewInitialAdapter 创建初始事实适配器。
+func NewInitialAdapter(target Node) *InitialAdapter { return &InitialAdapter{target: target} }
+
+func (a *InitialAdapter) AssertFact(f model.Fact)  {}
+func (a *InitialAdapter) RetractFact(f model.Fact) {}
+func (a *InitialAdapter) AssertToken(t Token)      { a.target.AssertToken(NewToken(nil)) }
+func (a *InitialAdapter) RetractToken(t Token)     { a.target.RetractToken(NewToken(nil)) }
+
+// AddChild 适配器没有自己的子节点，子节点应挂在目标节点上。
+func (a *InitialAdapter) AddChild(n Node) { a.target.AddChild(n) }
@@ -8,67 +8,161 @@ import (
 // AggregateFunc 定义了从事实中提取分组键的函数。
 type AggregateFunc func(f model.Fact) (groupKey string, ok bool)
 
-// AggregateNode 实现聚合功能，例如 "count(事实) > N"。
+// ValueFunc 定义了从事实中提取被聚合字段值的函数。
+type ValueFunc func(f model.Fact) interface{}
+
+// ResultTest 判断分组的聚合结果是否满足约束（如 "sum > 100000"）。
+type ResultTest func(result float64) bool
+
+// AggregateSpec 描述一个聚合节点的计算方式。
+type AggregateSpec struct {
+	Function    string             // 聚合函数名，仅用于标记结果
+	GroupBy     AggregateFunc      // 分组键
+	Value       ValueFunc          // 被聚合字段，count 可为空
+	Accumulator AccumulatorFactory // 为每个分组创建累加器
+	Test        ResultTest         // 结果约束
+}
+
+// AggregateNode 实现聚合功能，例如 "count(事实) >= N"、"sum(Amount) > X"。
 //
 // 工作流程:
 // 1. AssertFact: 当一个 Fact 到达时，使用 groupBy 函数提取其分组键。
-//   - 对应分组的计数器加一。
-//   - 如果计数值 **首次** 达到或超过阈值 (threshold)，则生成一个特殊的聚合结果事实
-//     (AggregateResult) 并向下游传播。
+//   - 将被聚合字段的值累加到该分组的 Accumulator 中。
+//   - 如果分组结果从不满足约束变为满足约束，则生成一个聚合结果事实
+//     (AggregateResult)，并以 Fact 与单元素 Token 两种形式向下游传播。
+//
+// 2. RetractFact: 撤回一个 Fact 会对其分组做反向累加。
+//   - 如果分组结果从满足约束变为不满足约束，则向下游传播对该分组聚合结果的撤回。
+//   - 之后若结果再次满足约束，会以最新的 Count/Value 重新断言聚合结果。
 //
-// 2. RetractFact: (为保持示例简洁，本实现 **未** 完整支持撤回)
-//   - 理想情况下，撤回一个 Fact 会使其分组计数减一。
-//   - 如果计数值从阈值之上降到阈值之下，则应向下游传播对聚合结果事实的撤回。
+// 分组持续满足约束期间，结果的每次变化都以"撤回旧结果、断言新结果"传播，下游不会看到过期的值。
 type AggregateNode struct {
 	baseNode
-	groupBy    AggregateFunc
-	threshold  int
+	spec       AggregateSpec
 	rightFacts *AlphaMemory
-	counts     map[string]int // groupKey -> count
+	counts     map[string]int             // groupKey -> 分组内事实数量
+	groups     map[string]Accumulator     // groupKey -> 累加器
+	results    map[string]AggregateResult // groupKey -> 已传播的聚合结果
 }
 
 // AggregateResult 是一个特殊的事实，代表聚合运算的结果。
 type AggregateResult struct {
 	GroupKey string
-	Count    int
+	Function string
+	Count    int     // 分组内事实数量
+	Value    float64 // 聚合函数计算结果
 }
 
 func (ar AggregateResult) Key() string { return fmt.Sprintf("agg:%s", ar.GroupKey) }
 
+// NewAggregateNode 创建一个计数聚合节点：分组计数达到 threshold 时传播结果。
 func NewAggregateNode(groupBy AggregateFunc, threshold int) *AggregateNode {
+	return NewAggregateNodeFromSpec(AggregateSpec{
+		Function:    "count",
+		GroupBy:     groupBy,
+		Accumulator: accumulators["count"],
+		Test:        func(result float64) bool { return result >= float64(threshold) },
+	})
+}
+
+// NewAggregateNodeFromSpec 按 AggregateSpec 创建聚合节点。
+func NewAggregateNodeFromSpec(spec AggregateSpec) *AggregateNode {
 	return &AggregateNode{
-		groupBy:    groupBy,
-		threshold:  threshold,
+		spec:       spec,
 		rightFacts: NewAlphaMemory(),
 		counts:     make(map[string]int),
+		groups:     make(map[string]Accumulator),
+		results:    make(map[string]AggregateResult),
 	}
 }
 
 func (a *AggregateNode) AssertFact(f model.Fact) {
-	// 仅当事实满足分组条件时，才进行聚合
-	if !a.rightFacts.Add(f) {
-		return
-	}
-
 	// 使用 groupBy 函数提取分组键
 	// 如果无法提取分组键，则忽略该事实
-	key, ok := a.groupBy(f)
+	key, ok := a.spec.GroupBy(f)
 	if !ok {
 		return
 	}
 
-	// 仅当计数从 threshold-1 上升到 threshold 时，才传播断言
-	if a.counts[key] == a.threshold-1 {
-		resultFact := AggregateResult{GroupKey: key, Count: a.threshold}
-		// 聚合节点将产生新的事实流
-		a.propagateAssertFact(resultFact)
+	// 同一事实只累加一次
+	if !a.rightFacts.Add(f) {
+		return
+	}
+
+	acc, ok := a.groups[key]
+	if !ok {
+		acc = a.spec.Accumulator()
+		a.groups[key] = acc
 	}
+	acc.Add(a.value(f))
 	a.counts[key]++
+	a.update(key)
 }
 
 func (a *AggregateNode) RetractFact(f model.Fact) {
-	// 简化：本实现不支持聚合节点的撤回。
-	// 在生产环境中，需要在这里实现计数减少和结果撤回的逻辑。
+	key, ok := a.spec.GroupBy(f)
+	if !ok {
+		return
+	}
+	if !a.rightFacts.Retract(f) {
+		return
+	}
+
+	a.groups[key].Remove(a.value(f))
+	a.counts[key]--
+	if a.counts[key] <= 0 {
+		delete(a.counts, key)
+		delete(a.groups, key)
+	}
+	a.update(key)
+}
+
+// value 提取事实中被聚合的字段值。
+func (a *AggregateNode) value(f model.Fact) interface{} {
+	if a.spec.Value == nil {
+		return nil
+	}
+	return a.spec.Value(f)
+}
+
+// update 根据分组当前结果决定是否断言、撤回或替换聚合结果。
+//   - 结果满足约束且尚未传播：断言新的 AggregateResult。
+//   - 结果不再满足约束且已传播：撤回之前传播的 AggregateResult。
+//   - 结果仍满足约束但 Count/Value 变化：撤回旧结果并断言新结果。
+func (a *AggregateNode) update(key string) {
+	prev, active := a.results[key]
+
+	holds := false
+	var result AggregateResult
+	if acc, ok := a.groups[key]; ok {
+		result = AggregateResult{
+			GroupKey: key,
+			Function: a.spec.Function,
+			Count:    a.counts[key],
+			Value:    acc.Result(),
+		}
+		holds = a.spec.Test(result.Value)
+	}
+
+	switch {
+	case holds && !active:
+		a.results[key] = result
+		a.propagateAssertFact(result)
+		a.propagateAssertToken(NewToken([]model.Fact{result}))
+
+	case !holds && active:
+		delete(a.results, key)
+		a.propagateRetractFact(prev)
+		a.propagateRetractToken(NewToken([]model.Fact{prev}))
+
+	case holds && active && prev != result:
+		// 仍然满足但结果变化：先撤回旧结果再传播新结果，下游总能看到最新的 Count/Value
+		a.results[key] = result
+		a.propagateRetractFact(prev)
+		a.propagateRetractToken(NewToken([]model.Fact{prev}))
+		a.propagateAssertFact(result)
+		a.propagateAssertToken(NewToken([]model.Fact{result}))
+	}
 }
 
 func (a *AggregateNode) AssertToken(t Token)  {}
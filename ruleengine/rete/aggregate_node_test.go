@@ -0,0 +1,166 @@
+package rete
+
+import (
+	"fmt"
+	"testing"
+
+	"code_for_article/ruleengine/model"
+)
+
+// recorder 是测试用的下游节点，记录收到的 Token 断言与撤回。
+type recorder struct {
+	baseNode
+	asserted  []Token
+	retracted []Token
+}
+
+func (r *recorder) AssertFact(f model.Fact)  {}
+func (r *recorder) RetractFact(f model.Fact) {}
+func (r *recorder) AssertToken(t Token)      { r.asserted = append(r.asserted, t) }
+func (r *recorder) RetractToken(t Token)     { r.retracted = append(r.retracted, t) }
+
+func TestAggregateNodeRetract(t *testing.T) {
+	agg := NewAggregateNode(func(f model.Fact) (string, bool) {
+		l, ok := f.(model.LoginAttempt)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprint(l.UserID), true
+	}, 2)
+	rec := &recorder{}
+	agg.AddChild(rec)
+
+	a1 := model.LoginAttempt{ID: 1, UserID: 7}
+	a2 := model.LoginAttempt{ID: 2, UserID: 7}
+	a3 := model.LoginAttempt{ID: 3, UserID: 7}
+
+	agg.AssertFact(a1)
+	agg.AssertFact(a2)
+	if len(rec.asserted) != 1 || len(rec.retracted) != 0 {
+		t.Fatalf("期望断言 1 次、撤回 0 次，实际 %d/%d", len(rec.asserted), len(rec.retracted))
+	}
+
+	// 2 -> 3：仍满足阈值，以最新计数替换结果
+	agg.AssertFact(a3)
+	if len(rec.asserted) != 2 || len(rec.retracted) != 1 {
+		t.Fatalf("期望替换结果（断言 2 次、撤回 1 次），实际 %d/%d", len(rec.asserted), len(rec.retracted))
+	}
+	if res := rec.asserted[1].Facts[0].(AggregateResult); res.Count != 3 {
+		t.Fatalf("期望最新计数 3，实际 %+v", res)
+	}
+
+	// 3 -> 2：仍满足阈值，替换为计数 2 的结果
+	agg.RetractFact(a3)
+	if len(rec.asserted) != 3 || len(rec.retracted) != 2 {
+		t.Fatalf("期望替换结果（断言 3 次、撤回 2 次），实际 %d/%d", len(rec.asserted), len(rec.retracted))
+	}
+
+	// 2 -> 1：跌破阈值，撤回聚合结果
+	agg.RetractFact(a2)
+	if len(rec.asserted) != 3 || len(rec.retracted) != 3 {
+		t.Fatalf("期望只撤回不断言，实际断言 %d 次、撤回 %d 次", len(rec.asserted), len(rec.retracted))
+	}
+	if rec.retracted[2].Hash() != rec.asserted[2].Hash() {
+		t.Fatalf("撤回的 Token 与断言的 Token 不一致")
+	}
+
+	// 1 -> 2：重新达到阈值，再次断言
+	agg.AssertFact(a3)
+	if len(rec.asserted) != 4 {
+		t.Fatalf("期望重新断言，实际断言 %d 次", len(rec.asserted))
+	}
+	res := rec.asserted[3].Facts[0].(AggregateResult)
+	if res.GroupKey != "7" || res.Count != 2 {
+		t.Fatalf("聚合结果不正确: %+v", res)
+	}
+}
+
+func TestAggregateNodeRefreshesSatisfiedResult(t *testing.T) {
+	factory, _ := LookupAccumulator("sum")
+	agg := NewAggregateNodeFromSpec(AggregateSpec{
+		Function:    "sum",
+		GroupBy:     func(f model.Fact) (string, bool) { return fmt.Sprint(f.(model.Transaction).UserID), true },
+		Value:       func(f model.Fact) interface{} { return f.(model.Transaction).Amount },
+		Accumulator: factory,
+		Test:        func(v float64) bool { return v > 500 },
+	})
+	rec := &recorder{}
+	agg.AddChild(rec)
+
+	for id, amount := range []float64{300, 300, 100, 200} {
+		agg.AssertFact(model.Transaction{ID: id + 1, UserID: 1, Amount: amount})
+	}
+
+	// 第 2 笔交易后满足约束，之后每笔交易都替换结果：下游只保留最新的一个结果
+	if len(rec.asserted)-len(rec.retracted) != 1 {
+		t.Fatalf("期望下游只有 1 个有效结果，实际断言 %d 次、撤回 %d 次", len(rec.asserted), len(rec.retracted))
+	}
+	res := rec.asserted[len(rec.asserted)-1].Facts[0].(AggregateResult)
+	if res.Count != 4 || res.Value != 900 {
+		t.Fatalf("期望第 4 笔交易后 Count=4、Value=900，实际 %+v", res)
+	}
+}
+
+func TestAggregateNodeAccumulators(t *testing.T) {
+	groupByUser := func(f model.Fact) (string, bool) {
+		tx, ok := f.(model.Transaction)
+		return fmt.Sprint(tx.UserID), ok
+	}
+	amount := func(f model.Fact) interface{} { return f.(model.Transaction).Amount }
+
+	txs := []model.Transaction{
+		{ID: 1, UserID: 1, Amount: 300},
+		{ID: 2, UserID: 1, Amount: 100},
+		{ID: 3, UserID: 1, Amount: 200},
+	}
+
+	cases := []struct {
+		function string
+		want     float64
+	}{
+		{"sum", 600},
+		{"avg", 200},
+		{"min", 100},
+		{"max", 300},
+		{"count_distinct", 3},
+	}
+	for _, c := range cases {
+		factory, ok := LookupAccumulator(c.function)
+		if !ok {
+			t.Fatalf("未找到聚合函数 %s", c.function)
+		}
+		agg := NewAggregateNodeFromSpec(AggregateSpec{
+			Function:    c.function,
+			GroupBy:     groupByUser,
+			Value:       amount,
+			Accumulator: factory,
+			Test:        func(v float64) bool { return v > 0 },
+		})
+		for _, tx := range txs {
+			agg.AssertFact(tx)
+		}
+		if got := agg.groups["1"].Result(); got != c.want {
+			t.Fatalf("%s: 期望 %v，实际 %v", c.function, c.want, got)
+		}
+	}
+
+	// min 在撤回最小值后应回退到次小值
+	factory, _ := LookupAccumulator("min")
+	agg := NewAggregateNodeFromSpec(AggregateSpec{
+		GroupBy: groupByUser, Value: amount, Accumulator: factory,
+		Test: func(v float64) bool { return v < 150 },
+	})
+	rec := &recorder{}
+	agg.AddChild(rec)
+	for _, tx := range txs {
+		agg.AssertFact(tx)
+	}
+	agg.RetractFact(txs[1])
+	if got := agg.groups["1"].Result(); got != 200 {
+		t.Fatalf("撤回后 min 期望 200，实际 %v", got)
+	}
+	// 100 进入后满足约束，200 进入时计数变化而替换结果，撤回 100 后不再满足
+	if len(rec.asserted) != 2 || len(rec.retracted) != 2 {
+		t.Fatalf("期望断言/撤回各 2 次，实际 %d/%d", len(rec.asserted), len(rec.retracted))
+	}
+}
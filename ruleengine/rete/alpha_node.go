@@ -14,10 +14,14 @@ type AlphaFunc func(f model.Fact) bool
 //     则将其存入 AlphaMemory，并同时向下游传播该事实及其对应的单元素 Token。
 //  2. RetractFact: 当一个事实被撤回时，如果它满足条件且存在于内存中，
 //     则从 AlphaMemory 中移除，并向下游传播撤回信号。
+//
+// 作为顶层节点时，可声明其接受的事实类型（见 NewTypedAlphaNode），
+// RootNode 只会把该类型的事实分派给它。
 type AlphaNode struct {
 	baseNode
-	cond   AlphaFunc
-	memory *AlphaMemory
+	factType string // 为空表示接受任意类型
+	cond     AlphaFunc
+	memory   *AlphaMemory
 }
 
 // NewAlphaNode 创建一个新的 AlphaNode。
@@ -25,6 +29,15 @@ func NewAlphaNode(f AlphaFunc) *AlphaNode {
 	return &AlphaNode{cond: f, memory: NewAlphaMemory()}
 }
 
+// NewTypedAlphaNode 创建一个只接受 factType 类型事实的 AlphaNode。
+// f 无需再检查事实类型。
+func NewTypedAlphaNode(factType string, f AlphaFunc) *AlphaNode {
+	return &AlphaNode{factType: factType, cond: f, memory: NewAlphaMemory()}
+}
+
+// FactType 返回节点声明的事实类型，为空表示接受任意类型。
+func (a *AlphaNode) FactType() string { return a.factType }
+
 // AssertFact 检查事实是否满足条件，如果满足，则存入内存并向下传播。
 func (a *AlphaNode) AssertFact(f model.Fact) {
 	if !a.cond(f) {
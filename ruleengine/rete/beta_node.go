@@ -18,19 +18,29 @@ type JoinFunc func(t Token, f model.Fact) bool
 // - RetractFact:
 //  1. 当 Token 或 Fact 被撤回时，从相应内存中移除。
 //  2. 同时，找到所有由它参与构成的下游 Token，并对它们发起撤回传播。
+//
+// 若提供了 JoinIndex，左右内存按连接键建立哈希索引，每次只访问连接键相同的对侧元素。
 type BetaNode struct {
 	baseNode
 	join       JoinFunc
+	index      *JoinIndex
 	leftTokens *BetaMemory
 	rightFacts *AlphaMemory
 }
 
 // NewBetaNode 创建一个新的 BetaNode。
 func NewBetaNode(j JoinFunc) *BetaNode {
+	return NewIndexedBetaNode(j, nil)
+}
+
+// NewIndexedBetaNode 创建一个按 index 建立哈希索引的 BetaNode，index 为空时等同于 NewBetaNode。
+func NewIndexedBetaNode(j JoinFunc, index *JoinIndex) *BetaNode {
+	left, right := newJoinMemories(index)
 	return &BetaNode{
 		join:       j,
-		leftTokens: NewBetaMemory(),
-		rightFacts: NewAlphaMemory(),
+		index:      index,
+		leftTokens: left,
+		rightFacts: right,
 	}
 }
 
@@ -39,8 +49,8 @@ func (b *BetaNode) AssertToken(t Token) {
 	if !b.leftTokens.Add(t) {
 		return
 	}
-	// 与右侧所有事实进行 Join
-	for _, f := range b.rightFacts.Snapshot() {
+	// 与右侧（连接键相同的）事实进行 Join
+	for _, f := range rightCandidates(b.index, b.rightFacts, t) {
 		// 如果 Join 成功，则生成新的 Token 并传播
 		if b.join(t, f) {
 			newToken := extendToken(t, f)
@@ -59,7 +69,7 @@ func (b *BetaNode) RetractToken(t Token) {
 	// 撤回所有相关的下游 Token
 	// 这里的 Join 是为了找到所有与 t 相关的 Fact
 	// 并生成新的 Token 进行撤回传播
-	for _, f := range b.rightFacts.Snapshot() {
+	for _, f := range rightCandidates(b.index, b.rightFacts, t) {
 		if b.join(t, f) {
 			staleToken := extendToken(t, f)
 			b.propagateRetractToken(staleToken)
@@ -75,8 +85,8 @@ func (b *BetaNode) AssertFact(f model.Fact) {
 		return
 	}
 
-	// 与左侧所有 Token 进行 Join
-	for _, t := range b.leftTokens.Snapshot() {
+	// 与左侧（连接键相同的）Token 进行 Join
+	for _, t := range leftCandidates(b.index, b.leftTokens, f) {
 		if b.join(t, f) {
 			newToken := extendToken(t, f)
 			b.propagateAssertToken(newToken)
@@ -91,7 +101,7 @@ func (b *BetaNode) RetractFact(f model.Fact) {
 	}
 
 	// 撤回所有相关的下游 Token
-	for _, t := range b.leftTokens.Snapshot() {
+	for _, t := range leftCandidates(b.index, b.leftTokens, f) {
 		if b.join(t, f) {
 			staleToken := extendToken(t, f)
 			b.propagateRetractToken(staleToken)
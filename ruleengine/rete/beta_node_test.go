@@ -0,0 +1,76 @@
+package rete
+
+import (
+	"fmt"
+	"testing"
+
+	"code_for_article/ruleengine/model"
+)
+
+// userTxnJoin 连接 User Token 与同一用户的 Transaction。
+func userTxnJoin(t Token, f model.Fact) bool {
+	u, ok1 := t.Facts[0].(model.User)
+	tx, ok2 := f.(model.Transaction)
+	return ok1 && ok2 && u.ID == tx.UserID
+}
+
+var userTxnIndex = &JoinIndex{
+	Left: func(t Token) (string, bool) {
+		u, ok := t.Facts[0].(model.User)
+		return fmt.Sprint(u.ID), ok
+	},
+	Right: func(f model.Fact) (string, bool) {
+		tx, ok := f.(model.Transaction)
+		return fmt.Sprint(tx.UserID), ok
+	},
+}
+
+func TestIndexedBetaNodeMatchesUnindexed(t *testing.T) {
+	plain := NewBetaNode(userTxnJoin)
+	indexed := NewIndexedBetaNode(userTxnJoin, userTxnIndex)
+	plainRec, indexedRec := &recorder{}, &recorder{}
+	plain.AddChild(plainRec)
+	indexed.AddChild(indexedRec)
+
+	for _, n := range []*BetaNode{plain, indexed} {
+		n.AssertToken(NewToken([]model.Fact{model.User{ID: 1}}))
+		n.AssertFact(model.Transaction{ID: 10, UserID: 1})
+		n.AssertFact(model.Transaction{ID: 11, UserID: 2})
+		n.AssertToken(NewToken([]model.Fact{model.User{ID: 2}}))
+		n.AssertFact(model.Transaction{ID: 12, UserID: 1})
+		n.RetractFact(model.Transaction{ID: 10, UserID: 1})
+		n.RetractToken(NewToken([]model.Fact{model.User{ID: 2}}))
+	}
+
+	if len(plainRec.asserted) != 3 || len(indexedRec.asserted) != 3 {
+		t.Fatalf("期望断言 3 次，实际 普通 %d / 索引 %d", len(plainRec.asserted), len(indexedRec.asserted))
+	}
+	if len(plainRec.retracted) != 2 || len(indexedRec.retracted) != 2 {
+		t.Fatalf("期望撤回 2 次，实际 普通 %d / 索引 %d", len(plainRec.retracted), len(indexedRec.retracted))
+	}
+	for i := range plainRec.retracted {
+		if plainRec.retracted[i].Hash() != indexedRec.retracted[i].Hash() {
+			t.Fatalf("第 %d 次撤回的 Token 不一致", i)
+		}
+	}
+}
+
+// benchmarkBetaInsert 先插入 users 个用户 Token，再插入 b.N 笔交易。
+func benchmarkBetaInsert(b *testing.B, node *BetaNode) {
+	const users = 1000
+	for i := 0; i < users; i++ {
+		node.AssertToken(NewToken([]model.Fact{model.User{ID: i}}))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node.AssertFact(model.Transaction{ID: i, UserID: i % users})
+	}
+}
+
+func BenchmarkBetaNodeInsert(b *testing.B) {
+	benchmarkBetaInsert(b, NewBetaNode(userTxnJoin))
+}
+
+func BenchmarkIndexedBetaNodeInsert(b *testing.B) {
+	benchmarkBetaInsert(b, NewIndexedBetaNode(userTxnJoin, userTxnIndex))
+}
@@ -0,0 +1,51 @@
+package rete
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 提供窗口判断事实是否过期所用的当前时间。
+// 引擎默认使用系统时钟；测试或回放历史事件时可换成 PseudoClock，由调用方推进时间。
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc 将函数适配为 Clock。
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time { return f() }
+
+// SystemClock 返回系统时钟。
+func SystemClock() Clock { return ClockFunc(time.Now) }
+
+// PseudoClock 是只在调用 Advance / Set 时前进的时钟，使窗口的行为可以确定地测试。
+type PseudoClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewPseudoClock 创建一个从 start 开始的 PseudoClock。
+func NewPseudoClock(start time.Time) *PseudoClock {
+	return &PseudoClock{now: start}
+}
+
+func (c *PseudoClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance 将时钟向前推进 d。
+func (c *PseudoClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set 将时钟设置为 t。
+func (c *PseudoClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
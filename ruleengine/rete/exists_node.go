@@ -12,19 +12,29 @@ import "code_for_article/ruleengine/model"
 // - 状态转变点:
 //   - **AssertFact**: 当一个 Token 的匹配数从 0 增加到 1 时，传播断言。
 //   - **RetractFact**: 当一个 Token 的匹配数从 1 减少到 0 时，传播撤回。
+//
+// 与 BetaNode 一样，可通过 JoinIndex 为左右内存建立哈希索引。
 type ExistsNode struct {
 	baseNode
 	join        JoinFunc
+	index       *JoinIndex
 	leftMemory  *BetaMemory
 	rightMemory *AlphaMemory
 	counter     map[string]int // token.hash -> match count
 }
 
 func NewExistsNode(j JoinFunc) *ExistsNode {
+	return NewIndexedExistsNode(j, nil)
+}
+
+// NewIndexedExistsNode 创建一个按 index 建立哈希索引的 ExistsNode。
+func NewIndexedExistsNode(j JoinFunc, index *JoinIndex) *ExistsNode {
+	left, right := newJoinMemories(index)
 	return &ExistsNode{
 		join:        j,
-		leftMemory:  NewBetaMemory(),
-		rightMemory: NewAlphaMemory(),
+		index:       index,
+		leftMemory:  left,
+		rightMemory: right,
 		counter:     make(map[string]int),
 	}
 }
@@ -34,7 +44,7 @@ func (e *ExistsNode) AssertToken(t Token) {
 		return
 	}
 	count := 0
-	for _, f := range e.rightMemory.Snapshot() {
+	for _, f := range rightCandidates(e.index, e.rightMemory, t) {
 		if e.join(t, f) {
 			count++
 		}
@@ -60,7 +70,7 @@ func (e *ExistsNode) AssertFact(f model.Fact) {
 	if !e.rightMemory.Add(f) {
 		return
 	}
-	for _, t := range e.leftMemory.Snapshot() {
+	for _, t := range leftCandidates(e.index, e.leftMemory, f) {
 		if e.join(t, f) {
 			// 匹配数从 0 -> 1，触发断言
 			if e.counter[t.Hash()] == 0 {
@@ -75,7 +85,7 @@ func (e *ExistsNode) RetractFact(f model.Fact) {
 	if !e.rightMemory.Retract(f) {
 		return
 	}
-	for _, t := range e.leftMemory.Snapshot() {
+	for _, t := range leftCandidates(e.index, e.leftMemory, f) {
 		if e.join(t, f) {
 			e.counter[t.Hash()]--
 			// 匹配数从 1 -> 0，触发撤回
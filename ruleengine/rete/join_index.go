@@ -0,0 +1,52 @@
+package rete
+
+import "code_for_article/ruleengine/model"
+
+// TokenKeyFunc 从左侧 Token 中提取连接键。
+type TokenKeyFunc func(t Token) (key string, ok bool)
+
+// FactKeyFunc 从右侧 Fact 中提取连接键。
+type FactKeyFunc func(f model.Fact) (key string, ok bool)
+
+// JoinIndex 描述等值连接（如 LeftField == RightField）的连接键。
+//
+// Join 节点据此为左右两侧内存建立哈希索引：新 Token 到达时只与连接键相同的事实做 Join，
+// 新 Fact 到达时只与连接键相同的 Token 做 Join，避免扫描整个对侧内存。
+// 索引只是预过滤，候选对仍会经过 JoinFunc 的完整判断，因此连接键只需保证
+// “JoinFunc 成立 => 连接键相同”。无法提取连接键（ok 为 false）的一方不与任何对象匹配。
+type JoinIndex struct {
+	Left  TokenKeyFunc
+	Right FactKeyFunc
+}
+
+// newJoinMemories 按 index 创建左右两侧内存，index 为空时不建索引。
+func newJoinMemories(index *JoinIndex) (*BetaMemory, *AlphaMemory) {
+	if index == nil {
+		return NewBetaMemory(), NewAlphaMemory()
+	}
+	return NewIndexedBetaMemory(index.Left), NewIndexedAlphaMemory(index.Right)
+}
+
+// rightCandidates 返回可能与 Token t 连接的右侧事实。
+func rightCandidates(index *JoinIndex, right *AlphaMemory, t Token) []model.Fact {
+	if index == nil {
+		return right.Snapshot()
+	}
+	k, ok := index.Left(t)
+	if !ok {
+		return nil
+	}
+	return right.Lookup(k)
+}
+
+// leftCandidates 返回可能与 Fact f 连接的左侧 Token。
+func leftCandidates(index *JoinIndex, left *BetaMemory, f model.Fact) []Token {
+	if index == nil {
+		return left.Snapshot()
+	}
+	k, ok := index.Right(f)
+	if !ok {
+		return nil
+	}
+	return left.Lookup(k)
+}
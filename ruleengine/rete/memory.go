@@ -8,16 +8,33 @@ import (
 
 // AlphaMemory 存储通过 AlphaNode 条件过滤后的单一事实集合。
 // 使用 map 确保按 Key 唯一。
+//
+// 内存保存插入的事实本身：对指针事实保存的是指针，与调用方共享同一对象。
+// 撤回时按 Key 定位，但连接键索引与上游条件都按事实当前的字段计算，
+// 因此指针事实在工作内存中期间不能原地修改（见 model.Fact）。
+//
+// 作为 Join 节点的右侧内存时，可按连接键建立哈希索引（见 NewIndexedAlphaMemory），
+// 使 Join 只需访问连接键相同的事实。
 
 type AlphaMemory struct {
-	mu   sync.RWMutex
-	data map[string]model.Fact
+	mu      sync.RWMutex
+	data    map[string]model.Fact
+	index   FactKeyFunc                      // 为空表示不建索引
+	buckets map[string]map[string]model.Fact // 连接键 -> (fact.Key -> fact)
 }
 
 func NewAlphaMemory() *AlphaMemory {
 	return &AlphaMemory{data: make(map[string]model.Fact)}
 }
 
+// NewIndexedAlphaMemory 创建按 index 计算的连接键建立索引的 AlphaMemory。
+func NewIndexedAlphaMemory(index FactKeyFunc) *AlphaMemory {
+	m := NewAlphaMemory()
+	m.index = index
+	m.buckets = make(map[string]map[string]model.Fact)
+	return m
+}
+
 // Add 插入新 fact，若已存在则返回 false（未变更）。
 func (m *AlphaMemory) Add(f model.Fact) bool {
 	m.mu.Lock()
@@ -26,6 +43,16 @@ func (m *AlphaMemory) Add(f model.Fact) bool {
 		return false
 	}
 	m.data[f.Key()] = f
+	if m.index != nil {
+		if k, ok := m.index(f); ok {
+			bucket, exists := m.buckets[k]
+			if !exists {
+				bucket = make(map[string]model.Fact)
+				m.buckets[k] = bucket
+			}
+			bucket[f.Key()] = f
+		}
+	}
 	return true
 }
 
@@ -33,11 +60,33 @@ func (m *AlphaMemory) Add(f model.Fact) bool {
 func (m *AlphaMemory) Retract(f model.Fact) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, ok := m.data[f.Key()]; ok {
-		delete(m.data, f.Key())
-		return true
+	stored, ok := m.data[f.Key()]
+	if !ok {
+		return false
+	}
+	delete(m.data, f.Key())
+	if m.index != nil {
+		// 使用内存中保存的版本计算连接键，保证能定位到插入时的分桶
+		if k, ok := m.index(stored); ok {
+			delete(m.buckets[k], f.Key())
+			if len(m.buckets[k]) == 0 {
+				delete(m.buckets, k)
+			}
+		}
+	}
+	return true
+}
+
+// Lookup 返回连接键为 key 的事实副本，仅对建立了索引的内存有意义。
+func (m *AlphaMemory) Lookup(key string) []model.Fact {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	bucket := m.buckets[key]
+	out := make([]model.Fact, 0, len(bucket))
+	for _, v := range bucket {
+		out = append(out, v)
 	}
-	return false
+	return out
 }
 
 // Snapshot 返回当前 memory 的只读副本。
@@ -61,16 +110,27 @@ func (m *AlphaMemory) Size() int {
 // -------------------------------------------------------------------------
 
 // BetaMemory 存储 Join 结果（Token）。
+// 与 AlphaMemory 类似，作为 Join 节点的左侧内存时可按连接键建立哈希索引。
 
 type BetaMemory struct {
-	mu   sync.RWMutex
-	data map[string]Token // key = token.Hash()
+	mu      sync.RWMutex
+	data    map[string]Token // key = token.Hash()
+	index   TokenKeyFunc
+	buckets map[string]map[string]Token // 连接键 -> (token.Hash -> token)
 }
 
 func NewBetaMemory() *BetaMemory {
 	return &BetaMemory{data: make(map[string]Token)}
 }
 
+// NewIndexedBetaMemory 创建按 index 计算的连接键建立索引的 BetaMemory。
+func NewIndexedBetaMemory(index TokenKeyFunc) *BetaMemory {
+	m := NewBetaMemory()
+	m.index = index
+	m.buckets = make(map[string]map[string]Token)
+	return m
+}
+
 // Add 插入新 token；若已存在返回 false。
 func (m *BetaMemory) Add(t Token) bool {
 	m.mu.Lock()
@@ -79,6 +139,16 @@ func (m *BetaMemory) Add(t Token) bool {
 		return false
 	}
 	m.data[t.Hash()] = t
+	if m.index != nil {
+		if k, ok := m.index(t); ok {
+			bucket, exists := m.buckets[k]
+			if !exists {
+				bucket = make(map[string]Token)
+				m.buckets[k] = bucket
+			}
+			bucket[t.Hash()] = t
+		}
+	}
 	return true
 }
 
@@ -86,11 +156,32 @@ func (m *BetaMemory) Add(t Token) bool {
 func (m *BetaMemory) Retract(t Token) bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, ok := m.data[t.Hash()]; ok {
-		delete(m.data, t.Hash())
-		return true
+	stored, ok := m.data[t.Hash()]
+	if !ok {
+		return false
+	}
+	delete(m.data, t.Hash())
+	if m.index != nil {
+		if k, ok := m.index(stored); ok {
+			delete(m.buckets[k], t.Hash())
+			if len(m.buckets[k]) == 0 {
+				delete(m.buckets, k)
+			}
+		}
+	}
+	return true
+}
+
+// Lookup 返回连接键为 key 的 Token 副本，仅对建立了索引的内存有意义。
+func (m *BetaMemory) Lookup(key string) []Token {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	bucket := m.buckets[key]
+	out := make([]Token, 0, len(bucket))
+	for _, v := range bucket {
+		out = append(out, v)
 	}
-	return false
+	return out
 }
 
 func (m *BetaMemory) Snapshot() []Token {
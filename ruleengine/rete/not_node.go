@@ -23,19 +23,28 @@ import "code_for_article/ruleengine/model"
 //     如果这是它们最后一个匹配的 Fact，它们现在进入了“无匹配”状态，需要被传播。
 //
 // 为了精确实现撤回，我们使用一个 counter 来记录每个左侧 Token 的匹配数量。
+// 与 BetaNode 一样，可通过 JoinIndex 为左右内存建立哈希索引。
 type NotNode struct {
 	baseNode
 	join       JoinFunc
+	index      *JoinIndex
 	leftTokens *BetaMemory
 	rightFacts *AlphaMemory
 	counter    map[string]int // token.hash -> match count
 }
 
 func NewNotNode(j JoinFunc) *NotNode {
+	return NewIndexedNotNode(j, nil)
+}
+
+// NewIndexedNotNode 创建一个按 index 建立哈希索引的 NotNode。
+func NewIndexedNotNode(j JoinFunc, index *JoinIndex) *NotNode {
+	left, right := newJoinMemories(index)
 	return &NotNode{
 		join:       j,
-		leftTokens: NewBetaMemory(),
-		rightFacts: NewAlphaMemory(),
+		index:      index,
+		leftTokens: left,
+		rightFacts: right,
 		counter:    make(map[string]int),
 	}
 }
@@ -46,7 +55,7 @@ func (n *NotNode) AssertToken(t Token) {
 	}
 
 	count := 0
-	for _, f := range n.rightFacts.Snapshot() {
+	for _, f := range rightCandidates(n.index, n.rightFacts, t) {
 		if n.join(t, f) {
 			count++
 		}
@@ -73,7 +82,7 @@ func (n *NotNode) AssertFact(f model.Fact) {
 	if !n.rightFacts.Add(f) {
 		return
 	}
-	for _, t := range n.leftTokens.Snapshot() {
+	for _, t := range leftCandidates(n.index, n.leftTokens, f) {
 		if n.join(t, f) {
 			// 匹配数从 0 -> 1，意味着之前传播的 Token 需要被撤回
 			if n.counter[t.Hash()] == 0 {
@@ -88,7 +97,7 @@ func (n *NotNode) RetractFact(f model.Fact) {
 	if !n.rightFacts.Retract(f) {
 		return
 	}
-	for _, t := range n.leftTokens.Snapshot() {
+	for _, t := range leftCandidates(n.index, n.leftTokens, f) {
 		if n.join(t, f) {
 			n.counter[t.Hash()]--
 			// 匹配数从 1 -> 0，意味着这个 Token 现在没有匹配了，需要被传播
@@ -10,8 +10,12 @@ import (
 type AgendaAdder interface {
 	Add(ruleName string, tok Token, action func(), salience, specificity int)
 	AddLegacy(ruleName string, tok Token, action func()) // 兼容旧接口
+	Remove(ruleName string, tok Token) bool              // 取消尚未执行的激活项
 }
 
+// 一条规则可能由多个网络分支共享同一个 TerminalNode（如 OR 条件展开后的各分支），
+// 不同分支可能产生相同的 Token。TerminalNode 按 Token 计数：
+// 同一 Token 只在首次到达时产生激活项，在最后一次撤回时才取消激活项。
 type TerminalNode struct {
 	baseNode
 	ruleName    string
@@ -19,6 +23,11 @@ type TerminalNode struct {
 	action      func(Token)
 	salience    int // 规则优先级
 	specificity int // 规则特殊性
+
+	counts       map[string]int // token.hash -> 到达次数
+	assertHooks  []func(Token)  // Token 首次到达时的回调
+	retractHooks []func(Token)  // Token 撤回时的回调，如真值维护
+	firing       string         // 正在执行动作的 Token，见 fire
 }
 
 func NewTerminalNode(ruleName string, ag AgendaAdder, action func(Token), salience, specificity int) *TerminalNode {
@@ -28,6 +37,7 @@ func NewTerminalNode(ruleName string, ag AgendaAdder, action func(Token), salien
 		action:      action,
 		salience:    salience,
 		specificity: specificity,
+		counts:      make(map[string]int),
 	}
 }
 
@@ -36,15 +46,58 @@ func (t *TerminalNode) AssertFact(fact model.Fact) {
 }
 
 func (t *TerminalNode) AssertToken(tok Token) {
-	t.ag.Add(t.ruleName, tok, func() { t.action(tok) }, t.salience, t.specificity)
+	t.counts[tok.Hash()]++
+	if t.counts[tok.Hash()] > 1 {
+		return
+	}
+	for _, hook := range t.assertHooks {
+		hook(tok)
+	}
+	if tok.Hash() == t.firing {
+		return // 动作修改了自己匹配的事实，Token 重新到达时不再激活（refraction）
+	}
+	t.ag.Add(t.ruleName, tok, func() { t.fire(tok) }, t.salience, t.specificity)
+}
+
+// fire 执行激活项的动作。动作中 modify 自己匹配的事实会先撤回、再断言同一个 Token，
+// 若重新激活，规则会因自己的修改无限触发；因此执行期间同一 Token 的重新到达只计数、不激活。
+// 其他规则或之后的修改使 Token 重新匹配时仍会正常激活。
+func (t *TerminalNode) fire(tok Token) {
+	prev := t.firing
+	t.firing = tok.Hash()
+	defer func() { t.firing = prev }()
+	t.action(tok)
 }
 
 func (t *TerminalNode) RetractFact(fact model.Fact) {
 	// Terminal 不处理单独 Fact
 }
 
+// RetractToken 在 Token 被撤回时，从 Agenda 中取消对应的待执行激活项，
+// 避免事实在 AddFact 与 FireAllRules 之间被撤回后规则仍然触发。
 func (t *TerminalNode) RetractToken(tok Token) {
-	// Terminal 不处理 Token 的撤回
-	// 但可以在需要时添加逻辑来处理 Token 的撤回
-	// 例如：如果需要在 Token 被撤回时执行某些清理操作
+	count, ok := t.counts[tok.Hash()]
+	if !ok {
+		return
+	}
+	if count > 1 {
+		t.counts[tok.Hash()]--
+		return
+	}
+	delete(t.counts, tok.Hash())
+
+	t.ag.Remove(t.ruleName, tok)
+	for _, hook := range t.retractHooks {
+		hook(tok)
+	}
+}
+
+// OnRetract 注册 Token 撤回时的回调。
+func (t *TerminalNode) OnRetract(hook func(Token)) {
+	t.retractHooks = append(t.retractHooks, hook)
+}
+
+// OnAssert 注册 Token 首次到达时的回调。
+func (t *TerminalNode) OnAssert(hook func(Token)) {
+	t.assertHooks = append(t.assertHooks, hook)
 }
@@ -11,6 +11,9 @@ import (
 // Token 是 β 网络中向下传播的“事实组合链”。
 // 通过 Facts 切片串联起 join 过程中累计的事实，用于后续节点继续匹配。
 // Hash 字段用于在 BetaMemory 中做去重与检索。
+//
+// Hash 只由各事实的 Key 计算，与字段值无关：同一组事实（包括指针事实修改字段后）得到相同的 Token，
+// 撤回时据此找到对应的激活项。Facts 中的指针事实与工作内存共享，动作中读取到的是其当前值。
 
 type Token struct {
 	Facts []model.Fact
@@ -0,0 +1,157 @@
+package rete
+
+import (
+	"reflect"
+	"slices"
+
+	"code_for_article/ruleengine/model"
+)
+
+// ObjectTypeNode 位于根节点与 AlphaNode 之间，只接收某一类型的事实，
+// 并转发给声明了该类型的 AlphaNode。
+type ObjectTypeNode struct {
+	factType string
+	goType   reflect.Type // 绑定的 Go 类型（非指针），为空表示按类型名接收任意 Go 类型
+	children []*AlphaNode
+	seen     map[*AlphaNode]bool
+}
+
+// accepts 判断事实是否属于绑定的 Go 类型，区分不同包中的同名类型。
+func (o *ObjectTypeNode) accepts(f model.Fact) bool {
+	if o.goType == nil {
+		return true
+	}
+	t := reflect.TypeOf(f)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == o.goType
+}
+
+func newObjectTypeNode(factType string) *ObjectTypeNode {
+	return &ObjectTypeNode{factType: factType, seen: make(map[*AlphaNode]bool)}
+}
+
+// add 注册一个 AlphaNode，重复注册会被忽略（Builder 会在多条规则间共享 AlphaNode）。
+func (o *ObjectTypeNode) add(n *AlphaNode) {
+	if o.seen[n] {
+		return
+	}
+	o.seen[n] = true
+	o.children = append(o.children, n)
+}
+
+func (o *ObjectTypeNode) AssertFact(f model.Fact) {
+	for _, n := range o.children {
+		n.AssertFact(f)
+	}
+}
+
+func (o *ObjectTypeNode) RetractFact(f model.Fact) {
+	for _, n := range o.children {
+		n.RetractFact(f)
+	}
+}
+
+// RootNode 是 Rete 网络的入口，按事实类型把事实分派给对应的 ObjectTypeNode。
+//
+// 工作流程:
+//   - 声明了类型的 AlphaNode（见 NewTypedAlphaNode）挂在对应类型的 ObjectTypeNode 下，
+//     只会收到该类型的事实，免去对其他类型事实的条件计算。
+//   - 未声明类型的 AlphaNode 挂在通配节点下，接收所有事实，与旧版广播行为一致。
+//
+// 事实按 model.TypeNameOf（如 "User"）与 model.QualifiedTypeNameOf
+// （如 "code_for_article/ruleengine/model.User"）两个名称分派，指针事实与值事实分派到相同节点。
+// 通过 BindType 把类型名绑定到 Go 类型后，其他包中的同名类型不会进入该节点，
+// 该 Go 类型的事实也会分派到绑定的名称（名称可以与 Go 类型名不同）。
+type RootNode struct {
+	types    map[string]*ObjectTypeNode
+	bound    map[reflect.Type][]string // Go 类型 -> 绑定的类型名
+	wildcard *ObjectTypeNode
+}
+
+// NewRootNode 创建一个空的根节点。
+func NewRootNode() *RootNode {
+	return &RootNode{
+		types:    make(map[string]*ObjectTypeNode),
+		bound:    make(map[reflect.Type][]string),
+		wildcard: newObjectTypeNode(""),
+	}
+}
+
+// Add 注册顶层 AlphaNode，按其声明的类型挂到对应的 ObjectTypeNode 下。
+func (r *RootNode) Add(nodes ...*AlphaNode) {
+	for _, n := range nodes {
+		if n.FactType() == "" {
+			r.wildcard.add(n)
+			continue
+		}
+		r.typeNode(n.FactType()).add(n)
+	}
+}
+
+// BindType 将类型名 name 绑定到 Go 类型 t（指针取其元素类型），
+// 之后只有该 Go 类型的事实会分派给 name 对应的 AlphaNode。
+func (r *RootNode) BindType(name string, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	otn := r.typeNode(name)
+	if otn.goType == t {
+		return
+	}
+	otn.goType = t
+	r.bound[t] = append(r.bound[t], name)
+}
+
+func (r *RootNode) typeNode(name string) *ObjectTypeNode {
+	otn, ok := r.types[name]
+	if !ok {
+		otn = newObjectTypeNode(name)
+		r.types[name] = otn
+	}
+	return otn
+}
+
+// typeNodesOf 返回事实按类型名、带包路径的类型名以及绑定的类型名对应的 ObjectTypeNode。
+func (r *RootNode) typeNodesOf(f model.Fact) []*ObjectTypeNode {
+	names := []string{model.TypeNameOf(f), model.QualifiedTypeNameOf(f)}
+	if len(r.bound) > 0 {
+		t := reflect.TypeOf(f)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		names = append(names, r.bound[t]...)
+	}
+
+	var nodes []*ObjectTypeNode
+	for i, name := range names {
+		if slices.Contains(names[:i], name) {
+			continue
+		}
+		if otn, ok := r.types[name]; ok && otn.accepts(f) {
+			nodes = append(nodes, otn)
+		}
+	}
+	return nodes
+}
+
+// AssertFact 将事实分派给对应类型及通配的 AlphaNode，初始事实不分派给通配节点。
+func (r *RootNode) AssertFact(f model.Fact) {
+	for _, otn := range r.typeNodesOf(f) {
+		otn.AssertFact(f)
+	}
+	if _, initial := f.(InitialFact); !initial {
+		r.wildcard.AssertFact(f)
+	}
+}
+
+// RetractFact 将撤回信号分派给对应类型及通配的 AlphaNode。
+func (r *RootNode) RetractFact(f model.Fact) {
+	for _, otn := range r.typeNodesOf(f) {
+		otn.RetractFact(f)
+	}
+	if _, initial := f.(InitialFact); !initial {
+		r.wildcard.RetractFact(f)
+	}
+}
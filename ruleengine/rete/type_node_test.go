@@ -0,0 +1,61 @@
+package rete
+
+import (
+	"reflect"
+	"testing"
+
+	"code_for_article/ruleengine/model"
+)
+
+func TestRootNodeDispatchesByType(t *testing.T) {
+	var userCalls, anyCalls int
+	userAlpha := NewTypedAlphaNode("User", func(f model.Fact) bool {
+		userCalls++
+		return true
+	})
+	anyAlpha := NewAlphaNode(func(f model.Fact) bool {
+		anyCalls++
+		return true
+	})
+
+	root := NewRootNode()
+	root.Add(userAlpha, anyAlpha)
+	root.Add(userAlpha) // 重复注册应被忽略
+
+	root.AssertFact(model.User{ID: 1})
+	root.AssertFact(model.Transaction{ID: 1})
+	root.AssertFact(model.Account{ID: 1})
+
+	if userCalls != 1 {
+		t.Fatalf("User 类型节点期望只计算 1 次，实际 %d", userCalls)
+	}
+	if anyCalls != 3 {
+		t.Fatalf("未声明类型的节点期望接收全部 3 个事实，实际 %d", anyCalls)
+	}
+}
+
+// User 与 model.User 同名，用于检查按 Go 类型绑定的分派。
+type User struct{ ID int }
+
+func (u User) Key() string { return "rete.User" }
+
+func TestRootNodeBindTypeSeparatesSameNamedTypes(t *testing.T) {
+	var modelUsers, localUsers int
+	root := NewRootNode()
+	root.Add(
+		NewTypedAlphaNode("User", func(f model.Fact) bool { modelUsers++; return true }),
+		NewTypedAlphaNode("code_for_article/ruleengine/rete.User", func(f model.Fact) bool { localUsers++; return true }),
+	)
+	root.BindType("User", reflect.TypeOf(model.User{}))
+
+	root.AssertFact(model.User{ID: 1})
+	root.AssertFact(&model.User{ID: 2})
+	root.AssertFact(User{ID: 3})
+
+	if modelUsers != 2 {
+		t.Fatalf("User 节点期望接收值与指针形式的 model.User 共 2 次，实际 %d", modelUsers)
+	}
+	if localUsers != 1 {
+		t.Fatalf("带包路径的节点期望只接收 rete.User 1 次，实际 %d", localUsers)
+	}
+}
@@ -0,0 +1,147 @@
+package rete
+
+import (
+	"time"
+
+	"code_for_article/ruleengine/model"
+)
+
+// TimestampFunc 提取事实的事件时间，ok 为 false 表示事实没有有效的时间。
+type TimestampFunc func(f model.Fact) (t time.Time, ok bool)
+
+// WindowSpec 描述一个窗口，Length 与 Duration 二选一。
+type WindowSpec struct {
+	Tumbling  bool          // false 为滑动窗口，true 为滚动窗口
+	Length    int           // 按数量：窗口最多容纳的事实数
+	Duration  time.Duration // 按时间：窗口的时长
+	Timestamp TimestampFunc // 事件时间，为 nil 时以事实进入窗口时的时钟时间为准
+	Clock     Clock
+}
+
+// WindowNode 位于条件的 AlphaNode 与 AggregateNode / AccumulateNode 之间，
+// 只让窗口内的事实通过：事实离开窗口时向下游撤回，聚合节点随之做反向累加。
+//
+// 四种窗口:
+//   - 滑动数量窗口: 保留最近 Length 个事实，新事实进入时最早的事实离开。
+//   - 滚动数量窗口: 每 Length 个事实为一批，已满的批次在下一个事实到达时整体离开。
+//   - 滑动时间窗口: 保留事件时间在 (now-Duration, now] 内的事实（晚于 now 的事实也保留）。
+//   - 滚动时间窗口: 时间按 Duration 对齐为区间，只保留当前区间内的事实，进入下一个区间时整体离开。
+//
+// 时间窗口以 Clock 为准：插入事实时顺带清理过期事实，
+// 没有新事实时需调用 Expire（引擎的 Tick）使过期事实离开窗口。
+// 事件时间已在窗口之外的事实不会进入窗口。
+type WindowNode struct {
+	baseNode
+	spec    WindowSpec
+	entries []windowEntry // 窗口内的事实，按进入顺序
+}
+
+// windowEntry 是窗口内的一个事实及其时间。
+type windowEntry struct {
+	fact model.Fact
+	at   time.Time
+}
+
+// NewWindowNode 按 spec 创建 WindowNode，spec.Clock 为 nil 时使用系统时钟。
+func NewWindowNode(spec WindowSpec) *WindowNode {
+	if spec.Clock == nil {
+		spec.Clock = SystemClock()
+	}
+	return &WindowNode{spec: spec}
+}
+
+func (w *WindowNode) AssertFact(f model.Fact) {
+	now := w.spec.Clock.Now()
+	w.expire(now)
+
+	at := now
+	if w.spec.Timestamp != nil {
+		ts, ok := w.spec.Timestamp(f)
+		if !ok {
+			return
+		}
+		at = ts
+	}
+	if !w.inWindow(at, now) || w.find(f) >= 0 {
+		return
+	}
+
+	if n := w.spec.Length; n > 0 && len(w.entries) >= n {
+		if w.spec.Tumbling {
+			w.evict(len(w.entries))
+		} else {
+			w.evict(len(w.entries) - n + 1)
+		}
+	}
+	w.entries = append(w.entries, windowEntry{fact: f, at: at})
+	w.propagateAssertFact(f)
+}
+
+func (w *WindowNode) RetractFact(f model.Fact) {
+	i := w.find(f)
+	if i < 0 {
+		return // 已离开窗口
+	}
+	stored := w.entries[i].fact
+	w.entries = append(w.entries[:i], w.entries[i+1:]...)
+	w.propagateRetractFact(stored)
+}
+
+func (w *WindowNode) AssertToken(t Token)  {}
+func (w *WindowNode) RetractToken(t Token) {}
+
+// Expire 按时钟的当前时间撤回已离开时间窗口的事实，数量窗口不受影响。
+func (w *WindowNode) Expire() {
+	w.expire(w.spec.Clock.Now())
+}
+
+func (w *WindowNode) expire(now time.Time) {
+	if w.spec.Duration <= 0 {
+		return
+	}
+	kept := w.entries[:0]
+	var expired []model.Fact
+	for _, e := range w.entries {
+		if w.inWindow(e.at, now) {
+			kept = append(kept, e)
+		} else {
+			expired = append(expired, e.fact)
+		}
+	}
+	w.entries = kept
+	for _, f := range expired {
+		w.propagateRetractFact(f)
+	}
+}
+
+// inWindow 判断时间为 at 的事实在 now 时是否位于时间窗口内，数量窗口总是返回 true。
+func (w *WindowNode) inWindow(at, now time.Time) bool {
+	d := w.spec.Duration
+	switch {
+	case d <= 0:
+		return true
+	case w.spec.Tumbling:
+		return !at.Before(now.Truncate(d))
+	default:
+		return at.After(now.Add(-d))
+	}
+}
+
+// evict 撤回最早进入窗口的 n 个事实。
+func (w *WindowNode) evict(n int) {
+	evicted := w.entries[:n:n]
+	w.entries = w.entries[n:]
+	for _, e := range evicted {
+		w.propagateRetractFact(e.fact)
+	}
+}
+
+// find 返回 Key 与 f 相同的事实在窗口中的位置，不存在时返回 -1。
+func (w *WindowNode) find(f model.Fact) int {
+	for i, e := range w.entries {
+		if e.fact.Key() == f.Key() {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,91 @@
+package rete
+
+import (
+	"testing"
+	"time"
+
+	"code_for_article/ruleengine/model"
+)
+
+// factRecorder 是测试用的下游节点，记录当前通过窗口的事实。
+type factRecorder struct {
+	baseNode
+	facts map[string]bool
+}
+
+func (r *factRecorder) AssertFact(f model.Fact)  { r.facts[f.Key()] = true }
+func (r *factRecorder) RetractFact(f model.Fact) { delete(r.facts, f.Key()) }
+func (r *factRecorder) AssertToken(t Token)      {}
+func (r *factRecorder) RetractToken(t Token)     {}
+
+func loginAt(id int, at time.Time) model.LoginAttempt {
+	return model.LoginAttempt{ID: id, Timestamp: at.Unix()}
+}
+
+func loginTimestamp(f model.Fact) (time.Time, bool) {
+	return time.Unix(f.(model.LoginAttempt).Timestamp, 0), true
+}
+
+func TestWindowNodeLength(t *testing.T) {
+	sliding := NewWindowNode(WindowSpec{Length: 2})
+	tumbling := NewWindowNode(WindowSpec{Length: 2, Tumbling: true})
+	slidingRec := &factRecorder{facts: make(map[string]bool)}
+	tumblingRec := &factRecorder{facts: make(map[string]bool)}
+	sliding.AddChild(slidingRec)
+	tumbling.AddChild(tumblingRec)
+
+	for id := 1; id <= 3; id++ {
+		f := model.LoginAttempt{ID: id}
+		sliding.AssertFact(f)
+		tumbling.AssertFact(f)
+	}
+
+	// 滑动窗口保留最近 2 个事实；滚动窗口的第一批（1、2）在第 3 个事实到达时整体离开
+	if len(slidingRec.facts) != 2 || slidingRec.facts["LoginAttempt:1"] {
+		t.Fatalf("期望滑动窗口内为事实 2、3，实际 %v", slidingRec.facts)
+	}
+	if len(tumblingRec.facts) != 1 || !tumblingRec.facts["LoginAttempt:3"] {
+		t.Fatalf("期望滚动窗口内只有事实 3，实际 %v", tumblingRec.facts)
+	}
+
+	// 撤回窗口内的事实不会让已离开的事实回到窗口
+	sliding.RetractFact(model.LoginAttempt{ID: 3})
+	if len(slidingRec.facts) != 1 || !slidingRec.facts["LoginAttempt:2"] {
+		t.Fatalf("期望滑动窗口内只剩事实 2，实际 %v", slidingRec.facts)
+	}
+}
+
+func TestWindowNodeTimeExpiresWithClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewPseudoClock(start)
+	sliding := NewWindowNode(WindowSpec{Duration: 10 * time.Minute, Timestamp: loginTimestamp, Clock: clock})
+	tumbling := NewWindowNode(WindowSpec{Duration: 10 * time.Minute, Tumbling: true, Timestamp: loginTimestamp, Clock: clock})
+	slidingRec := &factRecorder{facts: make(map[string]bool)}
+	tumblingRec := &factRecorder{facts: make(map[string]bool)}
+	sliding.AddChild(slidingRec)
+	tumbling.AddChild(tumblingRec)
+
+	clock.Set(start.Add(8 * time.Minute))
+	for _, f := range []model.Fact{
+		loginAt(1, start.Add(-15*time.Minute)), // 事件时间已在两个窗口之外
+		loginAt(2, start.Add(time.Minute)),
+		loginAt(3, start.Add(7*time.Minute)),
+	} {
+		sliding.AssertFact(f)
+		tumbling.AssertFact(f)
+	}
+	if len(slidingRec.facts) != 2 || len(tumblingRec.facts) != 2 {
+		t.Fatalf("期望两个窗口内都是事实 2、3，实际 %v / %v", slidingRec.facts, tumblingRec.facts)
+	}
+
+	// 10:12：滑动窗口为 (10:02, 10:12]，事实 2 离开；滚动窗口进入 [10:10, 10:20)，全部离开
+	clock.Advance(4 * time.Minute)
+	sliding.Expire()
+	tumbling.Expire()
+	if len(slidingRec.facts) != 1 || !slidingRec.facts["LoginAttempt:3"] {
+		t.Fatalf("期望滑动窗口内只剩事实 3，实际 %v", slidingRec.facts)
+	}
+	if len(tumblingRec.facts) != 0 {
+		t.Fatalf("期望滚动窗口进入新区间后为空，实际 %v", tumblingRec.facts)
+	}
+}
@@ -0,0 +1,124 @@
+package ruleengine
+
+import "code_for_article/ruleengine/model"
+
+// 真值维护 (Truth Maintenance)
+//
+// 规则通过 "assert_logical" 动作插入的事实是逻辑事实：它由触发规则的 Token（称为“依据”）支撑，
+// 当依据在 TerminalNode 被撤回时，引擎自动撤回该事实。多个 Token 产生同一个 Key 的事实时按引用计数，
+// 只有最后一个依据消失才撤回。
+//
+// 依据的撤回发生在网络传播过程中，为避免在传播中途再次修改网络，依据的移除与失去全部依据的事实
+// 都先排队，待本次传播结束后再处理；同一次传播中重新匹配的 Token 保留其依据。
+
+// AddLogicalFact 以 justification 为依据插入逻辑事实。
+//   - 事实不存在：插入事实并记录依据。
+//   - 事实已作为逻辑事实存在：仅增加一个依据。
+//   - 事实已通过 AddFact 显式插入：显式事实不受真值维护管理，忽略本次依据。
+func (e *Engine) AddLogicalFact(f model.Fact, justification string) {
+	key := f.Key()
+	if _, ok := e.facts[key]; ok {
+		if _, logical := e.justified[key]; !logical {
+			return
+		}
+	} else {
+		e.justified[key] = make(map[string]bool)
+		e.AddFact(f)
+	}
+
+	if e.justified[key][justification] {
+		return
+	}
+	e.justified[key][justification] = true
+	e.supports[justification] = append(e.supports[justification], key)
+}
+
+// RemoveJustification 移除一个依据，失去全部依据的逻辑事实将被撤回。
+//
+// 传播期间的移除延迟到传播结束才生效：同一次传播中 Token 重新到达 TerminalNode 时
+// （如 UpdateFact 修改了支撑事实中与条件无关的字段），KeepJustification 会取消这次移除，
+// 逻辑事实不会被撤回后再等规则重新触发时才恢复。
+func (e *Engine) RemoveJustification(justification string) {
+	if e.propagating {
+		if !e.removing[justification] {
+			e.removing[justification] = true
+			e.pendingRemovals = append(e.pendingRemovals, justification)
+		}
+		return
+	}
+	e.removeJustification(justification)
+	e.retractUnsupported()
+}
+
+// KeepJustification 取消本次传播中对 justification 的移除，用于 Token 撤回后又重新匹配的情况。
+func (e *Engine) KeepJustification(justification string) {
+	delete(e.removing, justification)
+}
+
+// flushJustifications 在传播结束后移除仍未被保留的依据。
+func (e *Engine) flushJustifications() {
+	pending := e.pendingRemovals
+	e.pendingRemovals = nil
+	for _, justification := range pending {
+		if e.removing[justification] {
+			delete(e.removing, justification)
+			e.removeJustification(justification)
+		}
+	}
+}
+
+// removeJustification 移除依据，失去全部依据的逻辑事实进入撤回队列。
+func (e *Engine) removeJustification(justification string) {
+	keys, ok := e.supports[justification]
+	if !ok {
+		return
+	}
+	delete(e.supports, justification)
+
+	for _, key := range keys {
+		set, ok := e.justified[key]
+		if !ok {
+			continue
+		}
+		delete(set, justification)
+		if len(set) > 0 {
+			continue
+		}
+		delete(e.justified, key)
+		if f, ok := e.facts[key]; ok {
+			e.unsupported = append(e.unsupported, f)
+		}
+	}
+}
+
+// retractUnsupported 撤回排队中失去依据的逻辑事实。
+func (e *Engine) retractUnsupported() {
+	for len(e.unsupported) > 0 {
+		f := e.unsupported[0]
+		e.unsupported = e.unsupported[1:]
+		e.RetractFact(f)
+	}
+}
+
+// forgetJustifications 在逻辑事实被显式撤回时清理其依据记录。
+func (e *Engine) forgetJustifications(key string) {
+	set, ok := e.justified[key]
+	if !ok {
+		return
+	}
+	delete(e.justified, key)
+	for justification := range set {
+		keys := e.supports[justification]
+		for i, k := range keys {
+			if k == key {
+				keys = append(keys[:i], keys[i+1:]...)
+				break
+			}
+		}
+		if len(keys) == 0 {
+			delete(e.supports, justification)
+		} else {
+			e.supports[justification] = keys
+		}
+	}
+}